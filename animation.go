@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+)
+
+// decodeFrames decodes every frame of an animated GIF and composites them
+// into full-size images, honoring each frame's disposal method, so every
+// returned frame looks the way a viewer would show it at that point.
+func decodeFrames(data []byte) ([]image.Image, error) {
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	frames := make([]image.Image, 0, len(anim.Image))
+
+	for i, frame := range anim.Image {
+		// DisposalPrevious restores the canvas as it was before this frame
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, image.Point{}, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		snapshot := image.NewRGBA(bounds)
+		draw.Draw(snapshot, bounds, canvas, image.Point{}, draw.Src)
+		frames = append(frames, snapshot)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, nil
+}
+
+// exportPNGSequence renders every frame of an animated GIF and rasterizes each
+// render into a numbered PNG in dir (frame_0001.png, frame_0002.png, ...),
+// ready to be assembled into a video. process applies the same line
+// post-processing as regular output, and out selects the caption drawn on
+// every frame and the font and spacing. With bounce the frames are written
+// forward and then backward, see frameOrder. It returns the number of frames
+// written.
+func exportPNGSequence(data []byte, dir string, opts options, out outputOptions, bounce bool, process func(emit func(line string)) func(line string)) (int, error) {
+	frames, err := decodeFrames(data)
+	if err != nil {
+		return 0, err
+	}
+
+	rf := outputFont(out)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	order := frameOrder(len(frames), bounce)
+	for i, index := range order {
+		var art []string
+		opts.render(frames[index], process(func(line string) { art = append(art, line) }))
+		art = overlayCaption(art, out.caption, out.captionPos, out.captionEscape)
+
+		var buf bytes.Buffer
+		if err := writePNG(&buf, art, rf); err != nil {
+			return i, err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("frame_%04d.png", i+1))
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return i, err
+		}
+	}
+	return len(order), nil
+}
+
+// frameOrder returns the order in which n frames are played. Bounce playback
+// ("boomerang") runs forward and then backward through the same frames; the
+// first and last frames are not repeated at the turns, so looping the result
+// does not stall on them.
+func frameOrder(n int, bounce bool) []int {
+	order := make([]int, 0, 2*n)
+	for i := 0; i < n; i++ {
+		order = append(order, i)
+	}
+	if bounce {
+		for i := n - 2; i > 0; i-- {
+			order = append(order, i)
+		}
+	}
+	return order
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+)
+
+// browseWidthStep is the number of columns the + and - keys change the width
+// by in -browse mode.
+const browseWidthStep = 4
+
+// browse opens a full-screen viewer on the alternate screen that shows one
+// image of dir at a time. The arrow keys (or n and p) move between images, +
+// and - adjust the width, and q, Esc or Ctrl-C quit. Each key press re-renders
+// the current image fitted to the terminal; decoded images are reused until the
+// selection changes. process applies the same line post-processing as regular
+// output.
+func browse(dir string, strict bool, opts options, process func(emit func(line string)) func(line string)) error {
+	names, err := listImages(dir)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no images found")
+	}
+
+	tty, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+
+	restore, err := rawMode(tty, 0)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	tty.WriteString(altScreenOn)
+	defer tty.WriteString(altScreenOff)
+
+	index, loaded := 0, -1
+	var img image.Image
+	var loadErr error
+	key := make([]byte, 8)
+	for {
+		if loaded != index {
+			img, loadErr = decodeFile(filepath.Join(dir, names[index]), strict)
+			loaded = index
+		}
+
+		ws, err := terminalSize(tty)
+		if err != nil || ws.cols == 0 || ws.rows == 0 {
+			ws = winsize{rows: 24, cols: 80}
+		}
+		opts.width = min(opts.width, int(ws.cols))
+
+		status := fmt.Sprintf("%s (%d/%d)  width %d  left/right or n/p: navigate  +/-: width  q: quit",
+			names[index], index+1, len(names), opts.width)
+		if _, err := tty.WriteString(browseScreen(img, loadErr, status, opts, ws, process)); err != nil {
+			return err
+		}
+
+		n, err := tty.Read(key)
+		if err != nil {
+			return err
+		}
+		switch string(key[:n]) {
+		case "q", "\x1b", "\x03":
+			return nil
+		case "n", " ", "\x1b[C":
+			index = (index + 1) % len(names)
+		case "p", "\x1b[D":
+			index = (index + len(names) - 1) % len(names)
+		case "+", "=":
+			opts.width += browseWidthStep
+		case "-", "_":
+			opts.width = max(browseWidthStep, opts.width-browseWidthStep)
+		}
+	}
+}
+
+// browseScreen builds one frame of the -browse viewer: the image rendered to
+// fit above the status line, or the decode error in its place, followed by the
+// status line in reverse video on the last terminal row.
+func browseScreen(img image.Image, loadErr error, status string, opts options, ws winsize, process func(emit func(line string)) func(line string)) string {
+	var sb strings.Builder
+	sb.WriteString(clearScreen)
+
+	if loadErr != nil {
+		fmt.Fprintf(&sb, "Error: Failed to decode image: %v", loadErr)
+	} else {
+		if ws.rows > 1 {
+			opts.height = int(ws.rows) - 1
+		}
+		first := true
+		opts.render(img, process(func(line string) {
+			// Output processing is left on, so newlines still return the carriage
+			if !first {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(line)
+			first = false
+		}))
+	}
+
+	fmt.Fprintf(&sb, "\x1b[%d;1H\x1b[7m%s%s", ws.rows, fitLabel(status, int(ws.cols), false), ansiReset)
+	return sb.String()
+}
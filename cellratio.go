@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values of the -cell-ratio flag besides an explicit number.
+const cellRatioAuto = "auto"
+
+// defaultCellRatio is the width/height ratio assumed for a terminal cell when
+// -cell-ratio auto cannot measure the font.
+const defaultCellRatio = 0.5
+
+// cellRatioTimeout bounds how long -cell-ratio auto waits for the terminal to
+// answer its cell size query.
+const cellRatioTimeout = 200 * time.Millisecond
+
+// resolveCellRatio parses the -cell-ratio flag. An explicit number is used
+// as-is, while auto measures the terminal's cell size in pixels and falls back
+// to the default ratio, with a note, when the terminal doesn't report one.
+func resolveCellRatio(value string, quiet bool) (float64, error) {
+	if value != cellRatioAuto {
+		return parsePositiveFloat(value)
+	}
+
+	ratio, err := queryCellRatio(cellRatioTimeout)
+	if err != nil {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Note: Could not measure the terminal cell size (%v), assuming a cell ratio of %s\n", err, strconv.FormatFloat(defaultCellRatio, 'g', -1, 64))
+		}
+		return defaultCellRatio, nil
+	}
+	return ratio, nil
+}
+
+// parseCellSizeReply parses a terminal's answer to the CSI 16 t query, which
+// has the form ESC [ 6 ; <height> ; <width> t with the cell size in pixels, and
+// returns the cell's width/height ratio.
+func parseCellSizeReply(reply string) (float64, error) {
+	var height, width int
+	if _, err := fmt.Sscanf(reply, "\x1b[6;%d;%dt", &height, &width); err != nil || height <= 0 || width <= 0 {
+		return 0, fmt.Errorf("unexpected reply %q", reply)
+	}
+	return float64(width) / float64(height), nil
+}
+
+// queryCellRatio measures the terminal's cell width/height ratio. The window
+// size in pixels reported by the terminal driver is tried first since it needs
+// no round trip; terminals that leave it zero are asked with CSI 16 t, reading
+// the reply in raw mode until the timeout expires.
+func queryCellRatio(timeout time.Duration) (float64, error) {
+	tty, err := openTerminal()
+	if err != nil {
+		return 0, err
+	}
+	defer tty.Close()
+
+	if ws, err := terminalSize(tty); err == nil && ws.xPixels > 0 && ws.yPixels > 0 && ws.cols > 0 && ws.rows > 0 {
+		cellWidth := float64(ws.xPixels) / float64(ws.cols)
+		cellHeight := float64(ws.yPixels) / float64(ws.rows)
+		return cellWidth / cellHeight, nil
+	}
+
+	// Reads return after a tenth of a second without input
+	restore, err := rawMode(tty, 100*time.Millisecond)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+
+	if _, err := tty.WriteString("\x1b[16t"); err != nil {
+		return 0, err
+	}
+
+	var reply strings.Builder
+	buf := make([]byte, 32)
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); {
+		// A read that times out without input reports io.EOF
+		n, err := tty.Read(buf)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		reply.Write(buf[:n])
+		if strings.HasSuffix(reply.String(), "t") {
+			return parseCellSizeReply(reply.String())
+		}
+	}
+	return 0, fmt.Errorf("no reply within %v", timeout)
+}
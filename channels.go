@@ -0,0 +1,83 @@
+package main
+
+import (
+	"image"
+	"strings"
+)
+
+// Color channels the mono renderer can map instead of luminance.
+const (
+	channelLuma = iota
+	channelRed
+	channelGreen
+	channelBlue
+)
+
+// Supported values for the -split-layout flag.
+const (
+	splitSide  = "side"
+	splitStack = "stack"
+)
+
+// channelNames labels the grids produced by -split-channels.
+var channelNames = map[int]string{channelRed: "Red", channelGreen: "Green", channelBlue: "Blue"}
+
+// monoLuma returns the 8-bit value the mono renderer maps to a character for
+// a 16-bit color: its luminance, or a single channel's intensity when one is
+// selected.
+func (o options) monoLuma(r, g, b uint64) uint64 {
+	switch o.channel {
+	case channelRed:
+		return r >> 8
+	case channelGreen:
+		return g >> 8
+	case channelBlue:
+		return b >> 8
+	}
+	return luminance(r, g, b)
+}
+
+// splitChannels renders the red, green and blue channels of an image as three
+// labeled mono grids, either side by side or stacked, and passes the combined
+// lines to emit. Each grid goes through the regular mono renderer with only
+// the channel selector changed.
+func splitChannels(img image.Image, opts options, layout string, emit func(line string)) {
+	opts.color, opts.halfBlock = false, false
+
+	var grids [][]string
+	width := 0
+	for _, channel := range []int{channelRed, channelGreen, channelBlue} {
+		opts.channel = channel
+		lines := []string{""}
+		opts.render(img, func(line string) { lines = append(lines, line) })
+		grids = append(grids, lines)
+		if len(lines) > 1 {
+			width = max(width, visibleWidth(lines[1]))
+		}
+	}
+
+	for i, grid := range grids {
+		grid[0] = fitLabel(channelNames[i+channelRed], width, true)
+	}
+
+	if layout == splitStack {
+		for i, grid := range grids {
+			if i > 0 {
+				emit("")
+			}
+			for _, line := range grid {
+				emit(line)
+			}
+		}
+		return
+	}
+
+	// All grids share a height, so rows line up one to one
+	for y := range grids[0] {
+		parts := make([]string, len(grids))
+		for i, grid := range grids {
+			parts[i] = padVisible(grid[y], width)
+		}
+		emit(strings.Join(parts, indexGap))
+	}
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Supported values for the -colormode flag.
+const (
+	colorModeTrue = "truecolor"
+	colorMode256  = "256"
+)
+
+// Supported values for the -color-distance flag.
+const (
+	distanceRGB      = "rgb"
+	distanceCIE76    = "cie76"
+	distanceWeighted = "weighted"
+)
+
+const (
+	// ansiReset clears all color attributes
+	ansiReset = "\x1b[0m"
+
+	// upperHalfBlock is the glyph used by the half-block renderer
+	upperHalfBlock = "▀"
+)
+
+// cubeLevels are the channel intensities of the xterm-256 6x6x6 color cube.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// fgEscape returns the ANSI escape that sets the foreground color. In 256
+// mode the palette entry is matched with the given distance metric.
+// Format: \x1b[38;2;<r>;<g>;<b>m (truecolor) or \x1b[38;5;<n>m (256)
+func fgEscape(r, g, b uint8, mode, distance string) string {
+	if mode == colorMode256 {
+		return fmt.Sprintf("\x1b[38;5;%dm", xterm256(r, g, b, distance))
+	}
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, g, b)
+}
+
+// bgEscape returns the ANSI escape that sets the background color, matching
+// 256-mode palette entries like fgEscape.
+// Format: \x1b[48;2;<r>;<g>;<b>m (truecolor) or \x1b[48;5;<n>m (256)
+func bgEscape(r, g, b uint8, mode, distance string) string {
+	if mode == colorMode256 {
+		return fmt.Sprintf("\x1b[48;5;%dm", xterm256(r, g, b, distance))
+	}
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", r, g, b)
+}
+
+// xterm256 returns the index of the xterm-256 palette entry closest to the
+// given color. Both the 6x6x6 color cube (16-231) and the grayscale ramp
+// (232-255) are considered; the 16 system colors are skipped because their
+// exact values vary between terminals. With the plain RGB metric the nearest
+// cube entry is found per channel; the other metrics are not separable, so
+// every entry is compared.
+func xterm256(r, g, b uint8, distance string) int {
+	if distance != "" && distance != distanceRGB {
+		return nearestXterm(r, g, b, distance)
+	}
+
+	// Nearest color cube entry per channel
+	ri, gi, bi := cubeIndex(r), cubeIndex(g), cubeIndex(b)
+	cubeDist := colorDistance(r, g, b, cubeLevels[ri], cubeLevels[gi], cubeLevels[bi])
+
+	// Nearest grayscale ramp entry (8, 18, ..., 238)
+	avg := (int(r) + int(g) + int(b)) / 3
+	grayIdx := (avg - 3) / 10
+	if grayIdx < 0 {
+		grayIdx = 0
+	}
+	if grayIdx > 23 {
+		grayIdx = 23
+	}
+	grayLevel := 8 + grayIdx*10
+	grayDist := colorDistance(r, g, b, grayLevel, grayLevel, grayLevel)
+
+	if grayDist < cubeDist {
+		return 232 + grayIdx
+	}
+	return 16 + 36*ri + 6*gi + bi
+}
+
+// parseHexColor parses a color in RRGGBB form, with or without a leading '#'.
+func parseHexColor(s string) ([3]uint8, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return [3]uint8{}, fmt.Errorf("expected RRGGBB")
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return [3]uint8{}, fmt.Errorf("expected RRGGBB")
+	}
+	return [3]uint8{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// cubeIndex returns the index of the color cube level closest to v.
+func cubeIndex(v uint8) int {
+	if v < 48 {
+		return 0
+	}
+	if v < 115 {
+		return 1
+	}
+	return (int(v) - 35) / 40
+}
+
+// colorDistance returns the squared Euclidean distance between two RGB colors.
+func colorDistance(r, g, b uint8, r2, g2, b2 int) int {
+	dr := int(r) - r2
+	dg := int(g) - g2
+	db := int(b) - b2
+	return dr*dr + dg*dg + db*db
+}
+
+// xtermLab holds the CIE L*a*b* coordinates of the xterm-256 palette entries,
+// computed once for perceptual matching.
+var xtermLab = func() (lab [256][3]float64) {
+	for n := range lab {
+		c := xtermRGB(n)
+		lab[n] = rgbToLab(c.R, c.G, c.B)
+	}
+	return lab
+}()
+
+// nearestXterm returns the cube or grayscale ramp entry (16-255) closest to
+// the given color under a non-RGB distance metric.
+func nearestXterm(r, g, b uint8, distance string) int {
+	lab := rgbToLab(r, g, b)
+	best, bestDist := 16, math.Inf(1)
+	for n := 16; n < 256; n++ {
+		var d float64
+		if distance == distanceCIE76 {
+			d = labDistance(lab, xtermLab[n])
+		} else {
+			c := xtermRGB(n)
+			d = weightedDistance(r, g, b, int(c.R), int(c.G), int(c.B))
+		}
+		if d < bestDist {
+			best, bestDist = n, d
+		}
+	}
+	return best
+}
+
+// weightedDistance returns the squared RGB distance with each channel weighted
+// by its contribution to luma, so errors in green count most and blue least.
+func weightedDistance(r, g, b uint8, r2, g2, b2 int) float64 {
+	dr := float64(int(r) - r2)
+	dg := float64(int(g) - g2)
+	db := float64(int(b) - b2)
+	return 0.299*dr*dr + 0.587*dg*dg + 0.114*db*db
+}
+
+// labDistance returns the squared CIE76 color difference between two colors
+// in L*a*b* coordinates.
+func labDistance(a, b [3]float64) float64 {
+	dl, da, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dl*dl + da*da + db*db
+}
+
+// rgbToLab converts an sRGB color to CIE L*a*b* with a D65 white point.
+func rgbToLab(r, g, b uint8) [3]float64 {
+	linear := func(v uint8) float64 {
+		c := float64(v) / 255
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	lr, lg, lb := linear(r), linear(g), linear(b)
+
+	// Linear sRGB to XYZ, normalized by the D65 reference white
+	x := (0.4124*lr + 0.3576*lg + 0.1805*lb) / 0.95047
+	y := 0.2126*lr + 0.7152*lg + 0.0722*lb
+	z := (0.0193*lr + 0.1192*lg + 0.9505*lb) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// adjustColor applies the per-cell tone transforms to an averaged 16-bit color.
+// The transforms run in a fixed order: channel gains, then negative, then
+// solarize, then posterize. Gains therefore grade the original colors,
+// combining negative with solarize inverts everything before re-inverting the
+// channels that ended up above the solarize threshold, and posterizing last
+// keeps the output on its levels. Character selection uses the luminance of
+// the adjusted color, so it follows the posterized levels too.
+func (o options) adjustColor(r, g, b uint64) (uint64, uint64, uint64) {
+	r, g, b = o.applyGains(r, g, b)
+	return o.adjustChannel(r), o.adjustChannel(g), o.adjustChannel(b)
+}
+
+// applyGains multiplies each 16-bit channel by its gain, clamping to the
+// channel range. Monochrome output applies it before computing luminance so
+// character selection reflects the graded colors.
+func (o options) applyGains(r, g, b uint64) (uint64, uint64, uint64) {
+	return gainChannel(r, o.gainR), gainChannel(g, o.gainG), gainChannel(b, o.gainB)
+}
+
+// gainChannel scales a 16-bit channel value, clamped to 0..0xffff.
+func gainChannel(v uint64, gain float64) uint64 {
+	scaled := float64(v) * gain
+	if scaled > 0xffff {
+		return 0xffff
+	}
+	if scaled < 0 {
+		return 0
+	}
+	return uint64(scaled)
+}
+
+// adjustChannel applies the tone transforms to a single 16-bit channel.
+func (o options) adjustChannel(v uint64) uint64 {
+	if o.negative {
+		v = 0xffff - v
+	}
+	if o.solarize > 0 && v>>8 > uint64(o.solarize) {
+		v = 0xffff - v
+	}
+	return posterizeLevel(v, 0xffff, o.posterize)
+}
+
+// adjustGray applies the negative, solarize and posterize transforms to an
+// 8-bit luminance value, in the same order as adjustColor. Used by the
+// monochrome renderer, which applies the channel gains before computing
+// luminance.
+func (o options) adjustGray(gray uint64) uint64 {
+	if o.negative {
+		gray = 255 - gray
+	}
+	if o.solarize > 0 && gray > uint64(o.solarize) {
+		gray = 255 - gray
+	}
+	return posterizeLevel(gray, 255, o.posterize)
+}
+
+// posterizeLevel rounds a channel value in 0..maxValue to the nearest of n
+// evenly spaced levels, both ends included. n below 2 leaves it unchanged.
+func posterizeLevel(v, maxValue uint64, n int) uint64 {
+	if n < 2 {
+		return v
+	}
+	steps := uint64(n - 1)
+	return (v*steps + maxValue/2) / maxValue * maxValue / steps
+}
+
+// scanlineColor dims the 16-bit color of an odd pixel row by the scanline
+// intensity, leaving even rows untouched, for a CRT look.
+func (o options) scanlineColor(row int, r, g, b uint64) (uint64, uint64, uint64) {
+	if o.scanline == 0 || row%2 == 0 {
+		return r, g, b
+	}
+	keep := 1 - o.scanline
+	return gainChannel(r, keep), gainChannel(g, keep), gainChannel(b, keep)
+}
+
+// scanlineGray is the monochrome counterpart of scanlineColor. Odd rows are
+// pushed toward the light end of the palette by the scanline intensity, so
+// they read as fainter lines between the full-strength ones.
+func (o options) scanlineGray(row int, gray uint64) uint64 {
+	if o.scanline == 0 || row%2 == 0 {
+		return gray
+	}
+	return gray + uint64(math.Round(float64(255-gray)*o.scanline))
+}
+
+// styledCell is one visible character of a rendered line together with the
+// colors that were active when it was printed.
+type styledCell struct {
+	char         rune
+	fg, bg       color.RGBA
+	hasFg, hasBg bool
+}
+
+// parseCells splits a rendered line into its visible characters, tracking the
+// SGR color escapes (truecolor, 256-color and basic) that precede each one.
+// Other escape sequences are skipped.
+func parseCells(line string) []styledCell {
+	var cells []styledCell
+	var cur styledCell
+
+	for i := 0; i < len(line); {
+		if n := escapeLen(line, i); n > 0 {
+			// SGR escape: ESC [ params m
+			if line[i+1] == '[' && line[i+n-1] == 'm' {
+				cur.applySGR(line[i+2 : i+n-1])
+			}
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(line[i:])
+		cell := cur
+		cell.char = r
+		cells = append(cells, cell)
+		i += size
+	}
+	return cells
+}
+
+// visibleWidth returns the number of printable characters in a rendered line,
+// ignoring escape sequences. Unlike len(line) it matches the number of
+// terminal cells the line occupies, which padding and centering depend on.
+func visibleWidth(line string) int {
+	width := 0
+	for i := 0; i < len(line); {
+		if n := escapeLen(line, i); n > 0 {
+			i += n
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(line[i:])
+		width++
+		i += size
+	}
+	return width
+}
+
+// escapeLen returns the length of the escape sequence starting at line[i], or
+// 0 when none starts there. CSI sequences (ESC [ params final-byte) and OSC
+// sequences (ESC ] text, ended by BEL or ESC \) are recognized; an
+// unterminated sequence extends to the end of the line.
+func escapeLen(line string, i int) int {
+	if line[i] != '\x1b' || i+1 >= len(line) {
+		return 0
+	}
+
+	switch line[i+1] {
+	case '[':
+		j := i + 2
+		for j < len(line) && (line[j] < 0x40 || line[j] > 0x7e) {
+			j++
+		}
+		if j < len(line) {
+			j++
+		}
+		return j - i
+	case ']':
+		for j := i + 2; j < len(line); j++ {
+			if line[j] == '\a' {
+				return j + 1 - i
+			}
+			if line[j] == '\x1b' && j+1 < len(line) && line[j+1] == '\\' {
+				return j + 2 - i
+			}
+		}
+		return len(line) - i
+	}
+	return 0
+}
+
+// applySGR updates the cell's colors from the parameters of an SGR escape.
+func (c *styledCell) applySGR(params string) {
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		n, _ := strconv.Atoi(fields[i])
+		switch {
+		case n == 0:
+			c.hasFg, c.hasBg = false, false
+		case n == 38 || n == 48:
+			// Extended color: 5;<index> or 2;<r>;<g>;<b>
+			var col color.RGBA
+			if i+2 < len(fields) && fields[i+1] == "5" {
+				idx, _ := strconv.Atoi(fields[i+2])
+				col = xtermRGB(idx)
+				i += 2
+			} else if i+4 < len(fields) && fields[i+1] == "2" {
+				r, _ := strconv.Atoi(fields[i+2])
+				g, _ := strconv.Atoi(fields[i+3])
+				b, _ := strconv.Atoi(fields[i+4])
+				col = color.RGBA{uint8(r), uint8(g), uint8(b), 255}
+				i += 4
+			} else {
+				return
+			}
+			if n == 38 {
+				c.fg, c.hasFg = col, true
+			} else {
+				c.bg, c.hasBg = col, true
+			}
+		case n == 39:
+			c.hasFg = false
+		case n == 49:
+			c.hasBg = false
+		case n >= 30 && n <= 37:
+			c.fg, c.hasFg = xtermRGB(n-30), true
+		case n >= 90 && n <= 97:
+			c.fg, c.hasFg = xtermRGB(n-90+8), true
+		case n >= 40 && n <= 47:
+			c.bg, c.hasBg = xtermRGB(n-40), true
+		case n >= 100 && n <= 107:
+			c.bg, c.hasBg = xtermRGB(n-100+8), true
+		}
+	}
+}
+
+// systemColors are the conventional RGB values of the 16 basic ANSI colors.
+var systemColors = [16]color.RGBA{
+	{0, 0, 0, 255}, {205, 0, 0, 255}, {0, 205, 0, 255}, {205, 205, 0, 255},
+	{0, 0, 238, 255}, {205, 0, 205, 255}, {0, 205, 205, 255}, {229, 229, 229, 255},
+	{127, 127, 127, 255}, {255, 0, 0, 255}, {0, 255, 0, 255}, {255, 255, 0, 255},
+	{92, 92, 255, 255}, {255, 0, 255, 255}, {0, 255, 255, 255}, {255, 255, 255, 255},
+}
+
+// xtermRGB returns the RGB value of an xterm-256 palette index.
+func xtermRGB(n int) color.RGBA {
+	switch {
+	case n < 0 || n > 255:
+		return color.RGBA{0, 0, 0, 255}
+	case n < 16:
+		return systemColors[n]
+	case n < 232:
+		n -= 16
+		return color.RGBA{uint8(cubeLevels[n/36]), uint8(cubeLevels[n/6%6]), uint8(cubeLevels[n%6]), 255}
+	default:
+		v := uint8(8 + (n-232)*10)
+		return color.RGBA{v, v, v, 255}
+	}
+}
+
+// compactEscapes rewrites a rendered line so color escapes are only emitted
+// when the color changes, instead of the renderers' self-contained
+// <escape><char>reset form for every cell. A run of equally colored cells then
+// shares one escape, which shrinks colored output several times over; the line
+// still ends with a reset, so lines stay independent of each other. Lines
+// without escapes are returned unchanged.
+func compactEscapes(line string) string {
+	if !strings.Contains(line, "\x1b[") {
+		return line
+	}
+
+	var sb strings.Builder
+	var state, active []string // SGR escapes set since the last reset, and as emitted
+	for i := 0; i < len(line); {
+		if n := escapeLen(line, i); n > 0 {
+			esc := line[i : i+n]
+			switch {
+			case esc == ansiReset || esc == "\x1b[m":
+				state = state[:0]
+			case esc[1] == '[' && esc[n-1] == 'm':
+				state = setEscape(state, esc)
+			default:
+				sb.WriteString(esc)
+			}
+			i += n
+			continue
+		}
+
+		// Bring the emitted colors up to date before the character
+		if !slices.Equal(state, active) {
+			if !coversKinds(state, active) {
+				sb.WriteString(ansiReset)
+				active = active[:0]
+			}
+			for _, esc := range state {
+				if !slices.Contains(active, esc) {
+					sb.WriteString(esc)
+				}
+			}
+			active = append(active[:0], state...)
+		}
+
+		_, size := utf8.DecodeRuneInString(line[i:])
+		sb.WriteString(line[i : i+size])
+		i += size
+	}
+
+	if len(active) > 0 {
+		sb.WriteString(ansiReset)
+	}
+	return sb.String()
+}
+
+// escapeKind groups SGR escapes by the attribute they set: the foreground and
+// background colors are tracked separately, anything else as a whole.
+func escapeKind(esc string) string {
+	switch {
+	case strings.HasPrefix(esc, "\x1b[38;"):
+		return "fg"
+	case strings.HasPrefix(esc, "\x1b[48;"):
+		return "bg"
+	}
+	return esc
+}
+
+// setEscape adds an escape to the active set, replacing any escape of the same
+// kind it overrides.
+func setEscape(state []string, esc string) []string {
+	kind := escapeKind(esc)
+	for i, s := range state {
+		if escapeKind(s) == kind {
+			state[i] = esc
+			return state
+		}
+	}
+	return append(state, esc)
+}
+
+// coversKinds reports whether emitting the escapes of state on top of active
+// leaves no attribute of active in effect that state does not set, so no
+// reset is needed in between.
+func coversKinds(state, active []string) bool {
+	for _, a := range active {
+		if !slices.ContainsFunc(state, func(s string) bool { return escapeKind(s) == escapeKind(a) }) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// formatDecoders lists the decoders -format-in can select explicitly. The
+// packages register themselves for sniffing as well.
+var formatDecoders = map[string]func(io.Reader) (image.Image, error){
+	"png":  png.Decode,
+	"jpeg": jpeg.Decode,
+	"gif":  gif.Decode,
+	"bmp":  bmp.Decode,
+	"tiff": tiff.Decode,
+	"webp": webp.Decode,
+}
+
+// extensionAliases maps file extensions other than the format names in
+// formatDecoders to their format.
+var extensionAliases = map[string]string{
+	"jpg": "jpeg",
+	"tif": "tiff",
+}
+
+// inputFormats is the list of -format-in values shown in messages.
+const inputFormats = "png, jpeg, gif, bmp, tiff or webp"
+
+// File trailers checked by strict decoding.
+var (
+	pngTrailer  = []byte{0, 0, 0, 0, 'I', 'E', 'N', 'D', 0xae, 0x42, 0x60, 0x82}
+	jpegTrailer = []byte{0xff, 0xd9}
+	gifTrailer  = []byte{0x3b}
+)
+
+// fetchTimeout bounds how long downloading an image URL may take.
+const fetchTimeout = 30 * time.Second
+
+// decodeFile reads and decodes an image file, auto-detecting its format.
+func decodeFile(path string, strict bool) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(data, strict, "")
+}
+
+// decodeSource reads and decodes an image from a file path, URL or stdin,
+// using the given decoder or auto-detecting the format when it is empty.
+func decodeSource(source string, strict bool, format string) (image.Image, error) {
+	data, err := readSource(source)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(data, strict, format)
+}
+
+// readSource returns the contents of a file, of an http(s) URL, or of stdin
+// when the source is "-".
+func readSource(source string) ([]byte, error) {
+	if source == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	if !isURL(source) {
+		return os.ReadFile(source)
+	}
+
+	client := http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// isURL reports whether a source names an http or https URL.
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// decodeImage decodes an image with the named decoder, or auto-detects its
+// format when the name is empty. Some decoders return successfully for
+// truncated data, so in strict mode the file must also end with its format's
+// trailer and, for GIFs, every frame must decode.
+func decodeImage(data []byte, strict bool, format string) (image.Image, error) {
+	var img image.Image
+	var err error
+	if format != "" {
+		img, err = formatDecoders[format](bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decoding as %s: %w", format, err)
+		}
+	} else {
+		img, format, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil || !strict {
+		return img, err
+	}
+
+	switch format {
+	case "png":
+		if !bytes.HasSuffix(data, pngTrailer) {
+			return nil, fmt.Errorf("strict decode: png data is truncated (missing IEND chunk)")
+		}
+	case "jpeg":
+		if !bytes.HasSuffix(data, jpegTrailer) {
+			return nil, fmt.Errorf("strict decode: jpeg data is truncated or has trailing bytes (missing end-of-image marker)")
+		}
+	case "gif":
+		if !bytes.HasSuffix(data, gifTrailer) {
+			return nil, fmt.Errorf("strict decode: gif data is truncated (missing trailer)")
+		}
+		if _, err := gif.DecodeAll(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("strict decode: gif frames: %w", err)
+		}
+	}
+
+	return img, nil
+}
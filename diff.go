@@ -0,0 +1,62 @@
+package main
+
+import (
+	"image"
+)
+
+// diffASCII renders the per-cell difference between two images on a single
+// grid laid out from the first image. The second image is sampled onto the
+// same grid regardless of its size, so the two only need matching content,
+// not matching dimensions. Identical cells become spaces and larger
+// differences map to denser characters; in color mode the character is also
+// tinted red in proportion to the difference.
+// Rows are rendered in parallel and passed to emit in order.
+func diffASCII(a, b image.Image, opts options, emit func(line string)) {
+	opts = opts.sized(a.Bounds())
+	g := opts.layout(a.Bounds())
+	bWidth, bHeight := b.Bounds().Dx(), b.Bounds().Dy()
+
+	renderRows(g.rows, func(rowIndex int) string {
+		line := ""
+
+		// The same cell covers a different pixel range in each image
+		aY, aYEnd := span(rowIndex, g.tileRows, g.imgHeight)
+		bY, bYEnd := span(rowIndex, g.tileRows, bHeight)
+
+		for x := 0; x < g.cols; x++ {
+			aX, aXEnd := span(x, g.tileCols, g.imgWidth)
+			bX, bXEnd := span(x, g.tileCols, bWidth)
+
+			ar, ag, ab := sampleBlock(a, aX, aY, aXEnd, aYEnd)
+			br, bg, bb := sampleBlock(b, bX, bY, bXEnd, bYEnd)
+			delta := cellDelta(ar, ag, ab, br, bg, bb)
+
+			if delta == 0 {
+				line += " "
+				continue
+			}
+
+			// The palette runs dense to sparse, so invert the difference
+			char := string(paletteChar(palette, 255-delta))
+			if opts.color {
+				line += fgEscape(uint8(delta), 0, 0, opts.colorMode, opts.colorDistance) + char + ansiReset
+			} else {
+				line += char
+			}
+		}
+
+		return line
+	}, emit)
+}
+
+// cellDelta returns the difference between two 16-bit colors as an 8-bit
+// magnitude: the mean absolute difference of their channels.
+func cellDelta(r1, g1, b1, r2, g2, b2 uint64) uint64 {
+	abs := func(x, y uint64) uint64 {
+		if x > y {
+			return x - y
+		}
+		return y - x
+	}
+	return (abs(r1, r2) + abs(g1, g2) + abs(b1, b2)) / 3 >> 8
+}
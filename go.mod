@@ -1,3 +1,7 @@
 module pixelterm
 
 go 1.25.3
+
+require golang.org/x/image v0.36.0
+
+require golang.org/x/text v0.34.0 // indirect
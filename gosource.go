@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// formatGo emits the art as Go source for embedding in programs.
+const formatGo = "go"
+
+// goSource renders the art as a Go variable declaration holding one quoted
+// string per line, so escape sequences survive without hand-escaping. Print
+// it with strings.Join(name, "\n").
+func goSource(art []string, name string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// %s is ASCII art generated by pixelterm.\n", name)
+	fmt.Fprintf(&sb, "var %s = []string{\n", name)
+	for _, line := range art {
+		fmt.Fprintf(&sb, "\t%s,\n", strconv.Quote(line))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// indexGap separates neighboring thumbnails on a contact sheet row.
+const indexGap = "  "
+
+// isImageFile reports whether a file name has the extension of a format in
+// formatDecoders, or one of its extensionAliases.
+func isImageFile(name string) bool {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	if format, ok := extensionAliases[ext]; ok {
+		ext = format
+	}
+	_, ok := formatDecoders[ext]
+	return ok
+}
+
+// listImages returns the names of the files in dir with an image extension,
+// in directory order. It is shared by -index and -browse.
+func listImages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isImageFile(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// thumbnail is a rendered image together with the label shown beneath it.
+type thumbnail struct {
+	lines []string
+	label string
+}
+
+// contactSheet renders every image in dir as a small thumbnail labeled with
+// its filename and lays them out in rows of cols thumbnails. Long filenames are
+// shortened to the thumbnail width and optionally centered beneath it. Files
+// that fail to decode are reported on stderr and skipped.
+func contactSheet(dir string, cols int, strict, centerLabels bool, opts options) ([]string, error) {
+	names, err := listImages(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var thumbs []thumbnail
+	for _, name := range names {
+		img, err := decodeFile(filepath.Join(dir, name), strict)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping '%s': %v\n", name, err)
+			continue
+		}
+
+		var lines []string
+		opts.render(img, func(line string) { lines = append(lines, line) })
+		thumbs = append(thumbs, thumbnail{lines: lines, label: name})
+	}
+
+	if len(thumbs) == 0 {
+		return nil, fmt.Errorf("no images found")
+	}
+
+	// Every output row of thumbnails is padded to its tallest member, and
+	// every thumbnail line to the thumbnail width in visible cells
+	var sheet []string
+	for start := 0; start < len(thumbs); start += cols {
+		end := start + cols
+		if end > len(thumbs) {
+			end = len(thumbs)
+		}
+		row := thumbs[start:end]
+
+		// A blank line separates each row of thumbnails from the one above
+		if start > 0 {
+			sheet = append(sheet, "")
+		}
+
+		height := 0
+		for _, t := range row {
+			if len(t.lines) > height {
+				height = len(t.lines)
+			}
+		}
+
+		for y := 0; y < height; y++ {
+			parts := make([]string, len(row))
+			for i, t := range row {
+				line := ""
+				if y < len(t.lines) {
+					line = t.lines[y]
+				}
+				parts[i] = padVisible(line, opts.width)
+			}
+			sheet = append(sheet, strings.Join(parts, indexGap))
+		}
+
+		// Labels go on their own line beneath the thumbnails
+		labels := make([]string, len(row))
+		for i, t := range row {
+			labels[i] = fitLabel(t.label, opts.width, centerLabels)
+		}
+		sheet = append(sheet, strings.Join(labels, indexGap))
+	}
+
+	return sheet, nil
+}
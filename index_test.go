@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+func TestIsImageFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.png": true, "b.JPG": true, "c.jpeg": true, "d.gif": true,
+		"e.bmp": true, "f.tif": true, "g.TIFF": true, "h.webp": true,
+		"notes.txt": false, "png": false, "archive.tar.gz": false,
+	} {
+		if got := isImageFile(name); got != want {
+			t.Errorf("isImageFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
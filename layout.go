@@ -0,0 +1,189 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ellipsis marks a label that was shortened to fit.
+const ellipsis = "..."
+
+// labelBreaks are the characters a label may be shortened after, so cuts
+// land between words or filename parts rather than in the middle of one.
+const labelBreaks = " -_."
+
+// fitLabel fits a label into exactly width characters. Labels that are too
+// long are cut and end in an ellipsis, preferring to cut at a word boundary
+// when that keeps at least half of the available space. Shorter labels are
+// padded, either on the right or evenly on both sides when centered.
+func fitLabel(label string, width int, center bool) string {
+	runes := []rune(label)
+	if len(runes) > width {
+		if width <= len(ellipsis) {
+			runes = runes[:width]
+		} else {
+			keep := width - len(ellipsis)
+			cut := keep
+			for i := keep; i > keep/2; i-- {
+				if strings.ContainsRune(labelBreaks, runes[i]) {
+					cut = i
+					break
+				}
+			}
+			runes = append(runes[:cut:cut], []rune(ellipsis)...)
+		}
+	}
+
+	padding := width - len(runes)
+	if center {
+		left := padding / 2
+		return strings.Repeat(" ", left) + string(runes) + strings.Repeat(" ", padding-left)
+	}
+	return string(runes) + strings.Repeat(" ", padding)
+}
+
+// repeatLine joins n copies of a rendered line, placing sep between them.
+// Color escapes are self-contained per line, so copies never bleed together.
+func repeatLine(line string, n int, sep string) string {
+	copies := make([]string, n)
+	for i := range copies {
+		copies[i] = line
+	}
+	return strings.Join(copies, sep)
+}
+
+// padVisible pads a rendered line with spaces until it occupies width
+// terminal cells, measuring with visibleWidth so color escapes don't count.
+func padVisible(line string, width int) string {
+	if w := visibleWidth(line); w < width {
+		return line + strings.Repeat(" ", width-w)
+	}
+	return line
+}
+
+// Supported values for the -caption-pos flag.
+const (
+	captionTop    = "top"
+	captionCenter = "center"
+	captionBottom = "bottom"
+)
+
+// overlayCaption writes a caption over one row of the rendered art, replacing
+// the characters of the cells it covers. The caption is centered on the row's
+// visible width and shortened with an ellipsis when it does not fit. Empty
+// rows are skipped, moving up from the bottom and center positions and down
+// from the top, so trailing blank lines cannot swallow the caption. The
+// cells keep their own color escapes; when colorEscape is set it is placed
+// before each caption character so the text stands out from the art.
+func overlayCaption(art []string, caption, pos, colorEscape string) []string {
+	if caption == "" || len(art) == 0 {
+		return art
+	}
+
+	row, step := len(art)-1, -1
+	switch pos {
+	case captionTop:
+		row, step = 0, 1
+	case captionCenter:
+		row = len(art) / 2
+	}
+	for row >= 0 && row < len(art) && visibleWidth(art[row]) == 0 {
+		row += step
+	}
+	if row < 0 || row >= len(art) {
+		return art
+	}
+
+	width := visibleWidth(art[row])
+	text := []rune(strings.TrimRight(fitLabel(caption, min(width, utf8.RuneCountInString(caption)), false), " "))
+	start := (width - len(text)) / 2
+
+	captioned := append([]string(nil), art...)
+	captioned[row] = replaceCells(art[row], start, text, colorEscape)
+	return captioned
+}
+
+// replaceCells replaces the visible characters of a line starting at column
+// start with text, copying escape sequences through unchanged.
+func replaceCells(line string, start int, text []rune, colorEscape string) string {
+	var sb strings.Builder
+	col := 0
+	for i := 0; i < len(line); {
+		if n := escapeLen(line, i); n > 0 {
+			sb.WriteString(line[i : i+n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+		if col >= start && col < start+len(text) {
+			if colorEscape != "" {
+				sb.WriteString(colorEscape + string(text[col-start]) + ansiReset)
+			} else {
+				sb.WriteRune(text[col-start])
+			}
+		} else {
+			sb.WriteRune(r)
+		}
+		col++
+	}
+	return sb.String()
+}
+
+// asciiReplacement stands in for characters that -safe-ascii removes.
+const asciiReplacement = '?'
+
+// toSafeASCII replaces every character outside printable 7-bit ASCII with
+// asciiReplacement, copying escape sequences through unchanged so colored
+// output keeps its colors.
+func toSafeASCII(line string) string {
+	var sb strings.Builder
+	for i := 0; i < len(line); {
+		if n := escapeLen(line, i); n > 0 {
+			sb.WriteString(line[i : i+n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+		if r < ' ' || r > '~' {
+			r = asciiReplacement
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// trimTrailingSpace removes the whitespace at the end of a rendered line. In
+// colored lines a trailing space only counts as blank when no background color
+// is active, since a colored background is visible; the escapes of the removed
+// cells are dropped and a reset closes the line in their place.
+func trimTrailingSpace(line string) string {
+	var cur styledCell
+	end := 0 // byte offset just past the last visible cell
+	escaped := false
+	for i := 0; i < len(line); {
+		if n := escapeLen(line, i); n > 0 {
+			if line[i+1] == '[' && line[i+n-1] == 'm' {
+				cur.applySGR(line[i+2 : i+n-1])
+			}
+			escaped = true
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+		if !unicode.IsSpace(r) || cur.hasBg {
+			end = i
+		}
+	}
+
+	if !escaped || end == 0 {
+		return line[:end]
+	}
+	return line[:end] + ansiReset
+}
@@ -1,21 +1,246 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
+	"go/token"
 	"image"
+	_ "image/gif"  // Register GIF format
 	_ "image/jpeg" // Register JPEG format
 	_ "image/png"  // Register PNG format
+	"io"
+	"math"
 	"os"
+	"runtime"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
+// Supported values for the -line-ending flag.
+const (
+	lineEndingLF   = "lf"
+	lineEndingCRLF = "crlf"
+)
+
+// lineEndings maps -line-ending values to the separators they write.
+var lineEndings = map[string]string{lineEndingLF: "\n", lineEndingCRLF: "\r\n"}
+
+// utf8BOM is the byte order mark written by -bom.
+const utf8BOM = "\ufeff"
+
+// renderJobs is the number of worker goroutines rendering rows, set by -jobs.
+// With a single worker rows are rendered one after another in order, exactly
+// like a serial loop.
+var renderJobs = runtime.NumCPU()
+
+// rowWindow bounds how many rows may be rendering or waiting to be emitted
+// ahead of the next row in order, which caps peak memory for very tall images.
+var rowWindow = 4 * renderJobs
+
+// palette is the ASCII ramp used for character selection, from dark to light.
+const palette = "@%#*+=-:. "
+
+// detailPalette is a longer ramp with finer tonal steps, used for busy cells
+// in -adaptive-detail mode while flat cells keep the simpler palette.
+const detailPalette = "$@B%8&WM#*oahkbdpqwmZO0QLCJUYXzcvunxrjft/\\|()1{}[]?-_+~<>i!lI;:,\"^`'. "
+
+// options holds the settings shared by the conversion functions.
+type options struct {
+	width     int     // output width in characters
+	scale     float64 // height scale factor (character aspect correction)
+	colorMode string  // colorModeTrue or colorMode256
+	color     bool    // emit color escapes
+	halfBlock bool    // use the half-block renderer (always colored)
+
+	// Palette matching metric in 256 mode, see xterm256
+	colorDistance string
+
+	// Per-cell tone transforms, see adjustColor for the order they apply in
+	negative  bool // invert every channel
+	solarize  int  // invert channels above this 8-bit threshold (0 disables)
+	posterize int  // snap channels to this many evenly spaced levels (0 disables)
+
+	gainR, gainG, gainB float64 // per-channel multipliers
+
+	// Global luminance adjustments, computed by prepareCurve before rendering
+	equalize     bool       // histogram-equalize luminance before palette mapping
+	autoContrast bool       // stretch the used luminance range to the full palette
+	contrastClip float64    // percent of cells ignored at each end by autoContrast
+	blackPoint   float64    // luminance percentile mapped to black (levels)
+	whitePoint   float64    // luminance percentile mapped to white (levels)
+	curve        *toneCurve // resulting tone curve, nil when no adjustment applies
+
+	// Dimming applied to odd rows for a CRT look, 0-1 (0 disables)
+	scanline float64
+
+	// Channel mapped by the mono renderer instead of luminance, see monoLuma
+	channel int
+
+	// Exponent applied to normalized luminance before indexing the ramp
+	rampGamma float64
+
+	// Hand-tuned luminance ranges used instead of the ramps (-ramp-map)
+	rampMap *rampMap
+
+	// Per-cell ramp selection: cells whose luminance standard deviation
+	// exceeds detailThreshold use detailPalette
+	adaptiveDetail  bool
+	detailThreshold float64
+
+	// Furthest factor the height scale may stray from the aspect-correct
+	// cell ratio (0 disables)
+	aspectLimit float64
+
+	// Aspect-correct cell ratio: the -cell-ratio value, measured or given,
+	// or defaultCellRatio
+	cellRatio float64
+
+	// Luminance sampling density for character selection, independent of
+	// the color averaging (0 uses the averaged color)
+	charDetail int
+
+	// Cells whose average alpha is below alphaThreshold are drawn as
+	// transparentChar, in transparentColor when coloring (empty disables)
+	transparentChar  string
+	transparentColor [3]uint8
+	alphaThreshold   uint64
+
+	// Alpha handling when averaging cells, see sampleColor
+	alphaWeighted bool // weight samples by alpha
+	checkerSize   int  // composite over a checkerboard of this size (0 disables)
+
+	// Cell color sampler, samplingBlock or samplingArea
+	sampling string
+
+	// Never draw half blocks, since they are not 7-bit ASCII (-safe-ascii)
+	safeASCII bool
+
+	// Grid sizing resolved per image, see sized
+	cellBudget    int  // target total cell count (0 keeps the width)
+	downscaleOnly bool // never render wider than the source image has pixels
+	height        int  // rows to fit within, or exactly when stretching (0 derives them)
+	stretch       bool // map the source onto width x height ignoring its aspect ratio
+
+	// Optional output grid size in characters that the image is repeated
+	// to fill (zero disables tiling)
+	tileCols, tileRows int
+}
+
+// outputOptions holds the settings for writing finished art.
+type outputOptions struct {
+	format   string // formatText, formatPNG, formatSVG or formatGo
+	fontPath string // font file for the raster formats
+	varName  string // variable name for Go source output
+	title    string // SAUCE title for ANSI art output
+	author   string // SAUCE author for ANSI art output
+	save     string // destination file, stdout when empty
+	quiet    bool   // suppress informational messages and notifications
+
+	// Caption overlaid on the art before it is written, see overlayCaption
+	caption, captionPos, captionEscape string
+
+	// Line ending and byte order mark for saved text, see -line-ending
+	lineEnding string
+	bom        bool
+
+	// Strip trailing blank cells from each line, see trimTrailingSpace
+	trimTrailing bool
+
+	// Emit color escapes only when the color changes, see compactEscapes
+	compactColor bool
+
+	// Extra cell spacing in pixels for the raster formats
+	letterSpacing, lineSpacing int
+
+	// Completion notifications, see notifyDone
+	notify    bool // ring the terminal bell
+	notifyOSC bool // also send an OSC 9 desktop notification
+}
+
 func main() {
 	// Define command-line flags
 	width := flag.Int("width", 100, "output width in characters")
+	height := flag.Int("height", 0, "output height in characters: the image is fitted within -width x -height, or stretched to it with -preserve-ratio=false (0 derives it from the aspect ratio)")
+	preserveRatio := flag.Bool("preserve-ratio", true, "keep the image's aspect ratio; false stretches it to exactly -width x -height")
 	scale := flag.Float64("scale", 0.15, "scale factor (affects height calculation)")
+	clampAspect := flag.Float64("clamp-aspect", 0, "keep the height scale within this factor of the aspect-correct cell ratio, e.g. 4 (0 disables)")
+	cellRatio := flag.String("cell-ratio", "", "terminal cell width/height ratio used for aspect correction instead of -scale, e.g. 0.5, or auto to measure the terminal")
 	color := flag.Bool("color", true, "enable colored ASCII output")
 	save := flag.String("save", "", "save output to file instead of printing to stdout")
+	halfBlock := flag.Bool("halfblock", false, "render two pixel rows per character using the upper half block (implies color)")
+	colorMode := flag.String("colormode", colorModeTrue, "color escape mode: truecolor or 256")
+	sampling := flag.String("sampling", samplingBlock, "how cell colors are averaged: block (a fast grid of samples from whole pixels) or area (every covered pixel, weighted by fractional coverage at the cell edges; slower but smoother)")
+	colorDistance := flag.String("color-distance", distanceRGB, "nearest-color metric for -colormode 256: rgb, cie76 (perceptual) or weighted (luma-weighted RGB)")
+	chunkOutput := flag.Bool("chunk-output", false, "stream rows to stdout as soon as they are ready instead of buffering the whole render")
+	negative := flag.Bool("negative", false, "invert the colors (or luminance in mono mode) of every cell")
+	solarize := flag.Int("solarize", 0, "invert only channels brighter than this threshold, 1-255 (0 disables)")
+	posterize := flag.Int("posterize", 0, "snap each color channel (or luminance in mono mode) to N evenly spaced levels for a poster look, 2-256; 4 gives 64 colors (applied after negative/solarize, 0 disables)")
+	gainR := flag.Float64("gain-r", 1.0, "multiply the red channel of every cell (applied before negative/solarize)")
+	gainG := flag.Float64("gain-g", 1.0, "multiply the green channel of every cell (applied before negative/solarize)")
+	gainB := flag.Float64("gain-b", 1.0, "multiply the blue channel of every cell (applied before negative/solarize)")
+	equalize := flag.Bool("equalize", false, "spread tones across the full palette with luminance histogram equalization")
+	autoContrast := flag.Bool("auto-contrast", false, "linearly stretch the image's luminance range so the darkest and brightest cells reach the ends of the palette")
+	contrastClip := flag.Float64("auto-contrast-clip", 0.5, "percent of cells at each end of the luminance range ignored as outliers by -auto-contrast")
+	scanline := flag.Bool("scanline", false, "dim every other row for a CRT look (odd rows in half-block mode are the bottom halves)")
+	scanlineIntensity := flag.Float64("scanline-intensity", 0.4, "how strongly -scanline dims rows, from 0 (no effect) to 1 (black, or blank in mono)")
+	blackPoint := flag.Float64("black-point", 0, "luminance percentile stretched to the darkest character, e.g. 1 (auto levels)")
+	whitePoint := flag.Float64("white-point", 100, "luminance percentile stretched to the lightest character, e.g. 99 (auto levels)")
+	rampMapPath := flag.String("ramp-map", "", "file of luminance ranges and characters ('0-30 @' per line, covering 0-255) used instead of the palette")
+	rampGamma := flag.Float64("ramp-gamma", 1.0, "power curve applied to luminance before picking a character (>1 favors darker characters, <1 lighter)")
+	adaptiveDetail := flag.Bool("adaptive-detail", false, "use a finer character ramp for busy cells and the simple ramp for flat ones")
+	detailThreshold := flag.Float64("detail-threshold", 12, "luminance standard deviation above which a cell counts as busy in -adaptive-detail mode")
+	downscaleOnly := flag.Bool("downscale-only", false, "never make the output wider than the source image (avoids blocky upscaling)")
+	charDetail := flag.Int("char-detail", 0, "pick characters from luminance sampled on an NxN grid per cell, while colors keep the standard averaging (0 = off)")
+	transparentAs := flag.String("transparent-as", "", "draw cells below the alpha threshold as this literal character, e.g. '·'")
+	transparentColor := flag.String("transparent-color", "#555555", "color of -transparent-as cells in color mode (hex RRGGBB)")
+	alphaThreshold := flag.Int("alpha-threshold", 128, "average alpha (0-255) below which a cell counts as transparent")
+	alphaWeighted := flag.Bool("alpha-weighted", false, "weight each sample's color by its alpha when averaging cells, so anti-aliased edges keep their true color")
+	checkerBg := flag.Bool("checker-bg", false, "show transparency as a gray and white checkerboard behind the image, like image editors")
+	checkerSize := flag.Int("checker-size", 4, "width of the -checker-bg squares in characters")
+	cellBudget := flag.Int("cell-budget", 0, "pick the width so the output holds about this many cells, preserving aspect (overrides -width)")
+	format := flag.String("format", formatText, "output format: text, png, svg, go (Go source) or ans (ANSI art with a SAUCE record)")
+	varName := flag.String("var", "art", "variable name used by -format go")
+	title := flag.String("title", "", "title stored in the SAUCE record of -format ans (cut to 35 characters; non-ASCII characters become '?')")
+	author := flag.String("author", "", "author stored in the SAUCE record of -format ans (cut to 20 characters; non-ASCII characters become '?')")
+	fontPath := flag.String("font", "", "TrueType/OpenType font used for png and svg output (default bundled Go Mono)")
+	letterSpacing := flag.String("letter-spacing", "0", "extra space between characters in png and svg output, in pixels or em (e.g. 2 or 0.1em)")
+	lineSpacing := flag.String("line-spacing", "0", "extra space between lines in png and svg output, in pixels or em (e.g. 4 or 0.25em)")
+	tile := flag.String("tile", "", "repeat the image to fill an output grid of WxH characters, e.g. 200x40")
+	safeASCII := flag.Bool("safe-ascii", false, "restrict output to printable 7-bit ASCII (plus color escapes): half blocks become colored characters and other glyphs are replaced with '?'")
+	caption := flag.String("caption", "", "overlay this text onto the art, centered horizontally")
+	captionPos := flag.String("caption-pos", captionBottom, "row the caption is placed on: top, center or bottom")
+	captionColor := flag.String("caption-color", "", "draw the caption in this color (hex RRGGBB) instead of the covered cells' colors")
+	repeat := flag.Int("repeat", 1, "repeat each output line N times across the row (banner strip)")
+	repeatSep := flag.String("repeat-sep", "", "separator placed between repetitions with -repeat")
+	manifestPath := flag.String("manifest", "", "process every entry of a manifest file: one path or URL per line, optionally followed by key=value overrides")
+	browseDir := flag.String("browse", "", "open a full-screen viewer for the images in this directory (arrows or n/p navigate, +/- change the width, q quits)")
+	indexDir := flag.String("index", "", "render a contact sheet of every image in this directory")
+	indexCols := flag.Int("index-cols", 4, "number of thumbnails per row in -index mode")
+	labelCenter := flag.Bool("label-center", false, "center filename labels beneath their thumbnails in -index mode")
+	indexWidth := flag.Int("index-width", 24, "thumbnail width in characters in -index mode")
+	formatIn := flag.String("format-in", "", "decode the input with this decoder instead of sniffing the format: "+inputFormats+" (useful with - for stdin)")
+	strictDecode := flag.Bool("strict-decode", false, "fail on truncated or corrupt images instead of rendering partial data")
+	lineEnding := flag.String("line-ending", lineEndingLF, "line ending of text saved with -save: lf or crlf")
+	bom := flag.Bool("bom", false, "start text saved with -save with a UTF-8 byte order mark")
+	compactColor := flag.Bool("compact-color", true, "emit color escapes only when the color changes, which makes colored text output several times smaller; false gives every character its own escape and reset, for tools that split output on escapes or cut lines by byte offset")
+	trimTrailing := flag.Bool("trim-trailing", false, "strip trailing whitespace from each line of text and ans output, dropping blank colored cells too (changes the grid width of light rows)")
+	warnLarge := flag.Int("warn-large", 50000, "ask for confirmation before printing more than this many cells to an interactive terminal (0 disables)")
+	yes := flag.Bool("yes", false, "skip the -warn-large confirmation")
+	quiet := flag.Bool("quiet", false, "suppress informational messages and completion notifications")
+	notify := flag.Bool("notify", false, "ring the terminal bell when processing finishes")
+	notifyOSC := flag.Bool("notify-osc", false, "also send an OSC 9 desktop notification when processing finishes (implies -notify)")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "number of rows rendered in parallel (1 renders serially, in order)")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile of the conversion to this file")
+	memProfile := flag.String("memprofile", "", "write a heap profile taken after conversion to this file")
+	exportPNGSeq := flag.String("export-png-seq", "", "render every frame of an animated GIF to numbered PNG files in this directory")
+	bounce := flag.Bool("bounce", false, "play the animation forward then backward (boomerang) in -export-png-seq, for GIFs that do not loop seamlessly")
+	splitChannelsFlag := flag.Bool("split-channels", false, "render the red, green and blue channels as three labeled mono grids")
+	splitLayout := flag.String("split-layout", splitSide, "arrangement of the -split-channels grids: side or stack")
+	diffMode := flag.Bool("diff", false, "render the per-cell difference between two images given as arguments (identical areas become spaces)")
+	palettePreview := flag.Bool("palette-preview", false, "print the active palette across a black-to-white gradient and exit (no image needed)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] <image-file>\n\n", os.Args[0])
@@ -24,10 +249,522 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\nExample:\n")
 		fmt.Fprintf(os.Stderr, "  %s -width 80 -color=false image.png\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -save output.txt image.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -save output.txt -line-ending crlf -bom image.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -color=false -trim-trailing -save logo.txt logo.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -compact-color=false photo.jpg | split-on-escapes\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -width 400 -yes poster.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -halfblock -colormode 256 image.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -colormode 256 -color-distance cie76 portrait.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -halfblock -posterize 4 poster.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -format png -font DejaVuSansMono.ttf -save art.png image.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -format svg -letter-spacing 1 -line-spacing 0.25em -save art.svg image.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -format go -var banner -width 40 logo.png > banner.go\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -auto-contrast -auto-contrast-clip 1 foggy.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -black-point 1 -white-point 99 photo.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -halfblock -scanline -scanline-intensity 0.6 retro.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -cell-budget 2000 image.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -width 80 -height 20 -preserve-ratio=false image.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -cell-ratio auto image.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -char-detail 8 -width 120 screenshot.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -halfblock -checker-bg -checker-size 2 sprite.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -sampling area -width 97 photo.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -transparent-as . -transparent-color 303030 logo.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -width 20 -tile 120x30 tile.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -caption 'HELLO WORLD' -caption-pos top -caption-color ffffff meme.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -safe-ascii -transparent-as . logo.png >> build.log\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -width 30 -repeat 3 -repeat-sep ' | ' logo.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -manifest list.txt   (lines like: photo.jpg width=80 color=false)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  curl -s https://example.com/photo | %s -format-in jpeg -\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -split-channels -split-layout stack -width 40 image.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -diff before.png after.png\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -export-png-seq frames/ -width 80 animation.gif\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -export-png-seq frames/ -bounce animation.gif\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -halfblock -format ans -title \"Sunset\" -author me -save sunset.ans sunset.jpg\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -index photos/ -index-cols 5\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -browse photos/\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -palette-preview -width 60\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -ramp-map mono-font.txt -palette-preview\n", os.Args[0])
 	}
 
 	flag.Parse()
 
+	if *jobs < 1 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid job count %d (must be at least 1)\n", *jobs)
+		os.Exit(1)
+	}
+
+	// Cap the CPU used by rendering, including the runtime's own threads
+	renderJobs = *jobs
+	rowWindow = 4 * *jobs
+	runtime.GOMAXPROCS(*jobs)
+
+	if *manifestPath != "" && *save != "" {
+		fmt.Fprintf(os.Stderr, "Error: -save would be overwritten by every manifest entry; set save= on each entry instead\n")
+		os.Exit(1)
+	}
+
+	if *chunkOutput && *save != "" {
+		fmt.Fprintf(os.Stderr, "Error: -chunk-output streams to stdout and cannot be combined with -save\n")
+		os.Exit(1)
+	}
+
+	switch *format {
+	case formatText, formatPNG, formatSVG, formatGo, formatANS:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Invalid format '%s' (expected %s, %s, %s, %s or %s)\n", *format, formatText, formatPNG, formatSVG, formatGo, formatANS)
+		os.Exit(1)
+	}
+
+	if *format == formatGo && !token.IsIdentifier(*varName) {
+		fmt.Fprintf(os.Stderr, "Error: Invalid variable name '%s' for -format go\n", *varName)
+		os.Exit(1)
+	}
+
+	if *chunkOutput && *format != formatText {
+		fmt.Fprintf(os.Stderr, "Error: -chunk-output only supports the %s format\n", formatText)
+		os.Exit(1)
+	}
+
+	if *colorMode != colorModeTrue && *colorMode != colorMode256 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid color mode '%s' (expected %s or %s)\n", *colorMode, colorModeTrue, colorMode256)
+		os.Exit(1)
+	}
+
+	if _, ok := formatDecoders[*formatIn]; *formatIn != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Error: Invalid input format '%s' (expected %s)\n", *formatIn, inputFormats)
+		os.Exit(1)
+	}
+
+	if *sampling != samplingBlock && *sampling != samplingArea {
+		fmt.Fprintf(os.Stderr, "Error: Invalid sampling '%s' (expected %s or %s)\n", *sampling, samplingBlock, samplingArea)
+		os.Exit(1)
+	}
+
+	switch *colorDistance {
+	case distanceRGB, distanceCIE76, distanceWeighted:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Invalid color distance '%s' (expected %s, %s or %s)\n", *colorDistance, distanceRGB, distanceCIE76, distanceWeighted)
+		os.Exit(1)
+	}
+
+	if *solarize < 0 || *solarize > 255 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid solarize threshold %d (expected 0-255)\n", *solarize)
+		os.Exit(1)
+	}
+
+	if *posterize != 0 && (*posterize < 2 || *posterize > 256) {
+		fmt.Fprintf(os.Stderr, "Error: Invalid posterize level count %d (expected 2-256, or 0 to disable)\n", *posterize)
+		os.Exit(1)
+	}
+
+	if *gainR < 0 || *gainG < 0 || *gainB < 0 {
+		fmt.Fprintf(os.Stderr, "Error: Channel gains must not be negative\n")
+		os.Exit(1)
+	}
+
+	if *rampGamma <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid ramp gamma %g (expected a positive value)\n", *rampGamma)
+		os.Exit(1)
+	}
+
+	var customRamp *rampMap
+	if *rampMapPath != "" {
+		var err error
+		customRamp, err = loadRampMap(*rampMapPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid ramp map: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Only one global tone curve can apply
+	if err := checkToneCurves(*equalize, *autoContrast, *blackPoint, *whitePoint); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *blackPoint < 0 || *whitePoint > 100 || *blackPoint >= *whitePoint {
+		fmt.Fprintf(os.Stderr, "Error: Invalid levels %g-%g (expected percentiles with 0 <= black < white <= 100)\n", *blackPoint, *whitePoint)
+		os.Exit(1)
+	}
+
+	if *contrastClip < 0 || *contrastClip >= 50 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid auto-contrast clip %g (expected 0 to below 50 percent)\n", *contrastClip)
+		os.Exit(1)
+	}
+
+	if *scanlineIntensity < 0 || *scanlineIntensity > 1 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid scanline intensity %g (expected 0-1)\n", *scanlineIntensity)
+		os.Exit(1)
+	}
+
+	// A zero intensity disables the effect
+	scanlineDim := 0.0
+	if *scanline {
+		scanlineDim = *scanlineIntensity
+	}
+
+	if !*preserveRatio && *height == 0 {
+		fmt.Fprintf(os.Stderr, "Error: -preserve-ratio=false needs an explicit -height\n")
+		os.Exit(1)
+	}
+
+	if *clampAspect != 0 && *clampAspect < 1 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid aspect clamp %g (must be at least 1, or 0 to disable)\n", *clampAspect)
+		os.Exit(1)
+	}
+
+	if *charDetail < 0 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid char detail %d (must be 0 or positive)\n", *charDetail)
+		os.Exit(1)
+	}
+
+	if *checkerSize < 1 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid checker size %d (must be at least 1)\n", *checkerSize)
+		os.Exit(1)
+	}
+
+	// A zero size disables the checkerboard
+	checkerSquare := 0
+	if *checkerBg {
+		checkerSquare = *checkerSize
+	}
+
+	if *transparentAs != "" && utf8.RuneCountInString(*transparentAs) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: -transparent-as must be a single character, got '%s'\n", *transparentAs)
+		os.Exit(1)
+	}
+
+	transparentRGB, err := parseHexColor(*transparentColor)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid transparent color '%s': %v\n", *transparentColor, err)
+		os.Exit(1)
+	}
+
+	if *alphaThreshold < 0 || *alphaThreshold > 255 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid alpha threshold %d (expected 0-255)\n", *alphaThreshold)
+		os.Exit(1)
+	}
+
+	if *cellBudget < 0 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid cell budget %d (expected a positive cell count)\n", *cellBudget)
+		os.Exit(1)
+	}
+
+	if *repeat < 1 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid repeat count %d (expected at least 1)\n", *repeat)
+		os.Exit(1)
+	}
+
+	if *indexCols < 1 || *indexWidth < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -index-cols and -index-width must be at least 1\n")
+		os.Exit(1)
+	}
+
+	// Frames are always decoded as GIF, see decodeFrames
+	if *exportPNGSeq != "" && *formatIn != "" && *formatIn != "gif" {
+		fmt.Fprintf(os.Stderr, "Error: -export-png-seq only reads GIF animations and cannot be combined with -format-in %s\n", *formatIn)
+		os.Exit(1)
+	}
+
+	if *bounce && *exportPNGSeq == "" {
+		fmt.Fprintf(os.Stderr, "Error: -bounce requires -export-png-seq\n")
+		os.Exit(1)
+	}
+
+	if *chunkOutput && (*indexDir != "" || *manifestPath != "" || *exportPNGSeq != "") {
+		fmt.Fprintf(os.Stderr, "Error: -chunk-output cannot be combined with -index, -manifest or -export-png-seq\n")
+		os.Exit(1)
+	}
+
+	if *chunkOutput && *caption != "" {
+		fmt.Fprintf(os.Stderr, "Error: -caption needs the whole render and cannot be combined with -chunk-output\n")
+		os.Exit(1)
+	}
+
+	if *lineEnding != lineEndingLF && *lineEnding != lineEndingCRLF {
+		fmt.Fprintf(os.Stderr, "Error: Invalid line ending '%s' (expected %s or %s)\n", *lineEnding, lineEndingLF, lineEndingCRLF)
+		os.Exit(1)
+	}
+
+	if *splitLayout != splitSide && *splitLayout != splitStack {
+		fmt.Fprintf(os.Stderr, "Error: Invalid split layout '%s' (expected %s or %s)\n", *splitLayout, splitSide, splitStack)
+		os.Exit(1)
+	}
+
+	switch *captionPos {
+	case captionTop, captionCenter, captionBottom:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Invalid caption position '%s' (expected %s, %s or %s)\n", *captionPos, captionTop, captionCenter, captionBottom)
+		os.Exit(1)
+	}
+
+	// The caption is overlaid after line post-processing, so guard it here
+	captionText := *caption
+	if *safeASCII {
+		captionText = toSafeASCII(captionText)
+	}
+
+	captionEscape := ""
+	if *captionColor != "" {
+		c, err := parseHexColor(*captionColor)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid caption color '%s': %v\n", *captionColor, err)
+			os.Exit(1)
+		}
+
+		// Plain -safe-ascii output carries no escapes at all
+		if !*safeASCII || *color || *halfBlock {
+			captionEscape = fgEscape(c[0], c[1], c[2], *colorMode, *colorDistance)
+		}
+	}
+
+	tileCols, tileRows, err := parseGridSize(*width, *height, *tile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	letterSpacingPx, err := parseSpacing(*letterSpacing)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid letter spacing '%s': %v\n", *letterSpacing, err)
+		os.Exit(1)
+	}
+	lineSpacingPx, err := parseSpacing(*lineSpacing)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid line spacing '%s': %v\n", *lineSpacing, err)
+		os.Exit(1)
+	}
+
+	// A cell ratio replaces the scale factor as the aspect correction
+	heightScale := *scale
+	aspectRatio := defaultCellRatio
+	if *cellRatio != "" {
+		ratio, err := resolveCellRatio(*cellRatio, *quiet)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid cell ratio '%s' (expected a positive number or %s)\n", *cellRatio, cellRatioAuto)
+			os.Exit(1)
+		}
+		heightScale, aspectRatio = ratio, ratio
+	}
+
+	opts := options{
+		width:     *width,
+		scale:     heightScale,
+		colorMode: *colorMode,
+		color:     *color,
+		halfBlock: *halfBlock,
+
+		colorDistance: *colorDistance,
+
+		negative:  *negative,
+		solarize:  *solarize,
+		posterize: *posterize,
+		gainR:     *gainR,
+		gainG:     *gainG,
+		gainB:     *gainB,
+		equalize:  *equalize,
+
+		autoContrast: *autoContrast,
+		contrastClip: *contrastClip,
+		blackPoint:   *blackPoint,
+		whitePoint:   *whitePoint,
+
+		scanline:  scanlineDim,
+		rampGamma: *rampGamma,
+		rampMap:   customRamp,
+
+		adaptiveDetail:  *adaptiveDetail,
+		detailThreshold: *detailThreshold,
+
+		aspectLimit: *clampAspect,
+		cellRatio:   aspectRatio,
+		charDetail:  *charDetail,
+
+		safeASCII: *safeASCII,
+
+		transparentChar:  *transparentAs,
+		transparentColor: transparentRGB,
+		alphaThreshold:   uint64(*alphaThreshold),
+		alphaWeighted:    *alphaWeighted,
+		checkerSize:      checkerSquare,
+		sampling:         *sampling,
+
+		cellBudget:    *cellBudget,
+		downscaleOnly: *downscaleOnly,
+		height:        *height,
+		stretch:       !*preserveRatio,
+		tileCols:      tileCols,
+		tileRows:      tileRows,
+	}
+
+	out := outputOptions{
+		format:   *format,
+		fontPath: *fontPath,
+		varName:  *varName,
+		title:    *title,
+		author:   *author,
+		save:     *save,
+		quiet:    *quiet,
+
+		lineEnding:   lineEndings[*lineEnding],
+		trimTrailing: *trimTrailing,
+		compactColor: *compactColor,
+		bom:          *bom,
+
+		caption:       captionText,
+		captionPos:    *captionPos,
+		captionEscape: captionEscape,
+
+		letterSpacing: letterSpacingPx,
+		lineSpacing:   lineSpacingPx,
+
+		notify:    *notify || *notifyOSC,
+		notifyOSC: *notifyOSC,
+	}
+
+	if *palettePreview {
+		for _, line := range previewPalette(opts) {
+			fmt.Println(line)
+		}
+		return
+	}
+
+	// postProcess wraps a line consumer with the line post-processing steps
+	postProcess := func(emit func(line string)) func(line string) {
+		// Replace anything that is not 7-bit ASCII as the last step
+		if *safeASCII {
+			next := emit
+			emit = func(line string) { next(toSafeASCII(line)) }
+		}
+
+		// Repeat every finished line across the row to build a banner strip
+		if *repeat > 1 {
+			next := emit
+			emit = func(line string) { next(repeatLine(line, *repeat, *repeatSep)) }
+		}
+		return emit
+	}
+
+	// streamProcess adds the line rewriting that writeOutput applies to whole
+	// renders, for lines that are shown as soon as they are ready
+	streamProcess := func(emit func(line string)) func(line string) {
+		if *compactColor {
+			next := emit
+			emit = func(line string) { next(compactEscapes(line)) }
+		}
+		if *trimTrailing {
+			next := emit
+			emit = func(line string) { next(trimTrailingSpace(line)) }
+		}
+		return postProcess(emit)
+	}
+
+	// Each manifest entry is rendered and written with its own merged options
+	if *manifestPath != "" {
+		entries, err := parseManifest(*manifestPath, opts, out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid manifest: %v\n", err)
+			os.Exit(1)
+		}
+
+		failed := false
+		stopProfiling := mustStartProfiling(*cpuProfile, *memProfile)
+		for _, entry := range entries {
+			img, err := decodeSource(entry.path, *strictDecode, *formatIn)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to load '%s' (%s:%d): %v\n", entry.path, *manifestPath, entry.line, err)
+				failed = true
+				continue
+			}
+
+			reportClamp(entry.opts, img.Bounds(), entry.out.quiet)
+			var art []string
+			entry.opts.render(img, postProcess(func(line string) { art = append(art, line) }))
+			writeOutput(art, entry.out)
+		}
+		stopProfiling()
+
+		out.notifyDone()
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Rows are printed as soon as they are ready in streaming mode,
+	// otherwise they are collected for a single write at the end
+	var art []string
+	emit := func(line string) { art = append(art, line) }
+	chunkErr := func() error { return nil }
+	if *chunkOutput {
+		emit, chunkErr = lineWriter(os.Stdout)
+		emit = streamProcess(emit)
+	} else {
+		emit = postProcess(emit)
+	}
+
+	// The viewer draws directly to the terminal instead of writing output
+	if *browseDir != "" {
+		if err := browse(*browseDir, *strictDecode, opts, streamProcess); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to browse '%s': %v\n", *browseDir, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The contact sheet replaces the single image render but shares the output path
+	if *indexDir != "" {
+		thumbOpts := opts
+		thumbOpts.width = *indexWidth
+
+		stopProfiling := mustStartProfiling(*cpuProfile, *memProfile)
+		sheet, err := contactSheet(*indexDir, *indexCols, *strictDecode, *labelCenter, thumbOpts)
+		stopProfiling()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to build contact sheet for '%s': %v\n", *indexDir, err)
+			os.Exit(1)
+		}
+		for _, line := range sheet {
+			emit(line)
+		}
+		writeOutput(art, out)
+		out.notifyDone()
+		return
+	}
+
+	// A diff renders one grid from two images but shares the output path
+	if *diffMode {
+		if flag.NArg() != 2 {
+			fmt.Fprintf(os.Stderr, "Error: -diff needs exactly two image files\n\n")
+			flag.Usage()
+			os.Exit(1)
+		}
+
+		var imgs [2]image.Image
+		for i, path := range flag.Args() {
+			img, err := decodeSource(path, *strictDecode, *formatIn)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to load image file '%s': %v\n", path, err)
+				os.Exit(1)
+			}
+			imgs[i] = img
+		}
+
+		stopProfiling := mustStartProfiling(*cpuProfile, *memProfile)
+		diffASCII(imgs[0], imgs[1], opts, emit)
+		stopProfiling()
+
+		if !*chunkOutput {
+			writeOutput(art, out)
+		} else if err := chunkErr(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to write output: %v\n", err)
+			os.Exit(1)
+		}
+		out.notifyDone()
+		return
+	}
+
 	// Check if an image file path was provided
 	if flag.NArg() < 1 {
 		fmt.Fprintf(os.Stderr, "Error: No image file specified\n\n")
@@ -37,176 +774,629 @@ func main() {
 
 	imagePath := flag.Arg(0)
 
-	// Open the image file
-	file, err := os.Open(imagePath)
+	// Read the image file
+	data, err := readSource(imagePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: Failed to open image file '%s': %v\n", imagePath, err)
 		os.Exit(1)
 	}
-	defer file.Close()
+
+	// Every frame of an animation is rasterized instead of printing one render
+	if *exportPNGSeq != "" {
+		// Validate the file up front so -strict-decode applies as usual
+		if *strictDecode {
+			if _, err := decodeImage(data, true, "gif"); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to decode image file '%s': %v\n", imagePath, err)
+				os.Exit(1)
+			}
+		}
+
+		stopProfiling := mustStartProfiling(*cpuProfile, *memProfile)
+		n, err := exportPNGSequence(data, *exportPNGSeq, opts, out, *bounce, postProcess)
+		stopProfiling()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to export frames of '%s' to '%s': %v\n", imagePath, *exportPNGSeq, err)
+			os.Exit(1)
+		}
+		if !*quiet {
+			fmt.Printf("Exported %d frames to '%s'\n", n, *exportPNGSeq)
+		}
+		out.notifyDone()
+		return
+	}
 
 	// Decode the image (format is auto-detected based on registered decoders)
-	img, _, err := image.Decode(file)
+	img, err := decodeImage(data, *strictDecode, *formatIn)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: Failed to decode image file '%s': %v\n", imagePath, err)
-		fmt.Fprintf(os.Stderr, "Hint: Ensure the file is a valid PNG or JPEG image.\n")
+		if *formatIn == "" {
+			fmt.Fprintf(os.Stderr, "Hint: Ensure the file is a valid PNG, JPEG, GIF, BMP, TIFF or WebP image, or force a decoder with -format-in.\n")
+		}
 		os.Exit(1)
 	}
 
-	// Generate ASCII art based on color flag
-	var art []string
-	if *color {
-		art = colorASCII(img, *width, *scale)
+	reportClamp(opts, img.Bounds(), *quiet)
+
+	// Give a chance to back out of flooding the terminal with a mistyped width
+	if *warnLarge > 0 && !*yes && *save == "" && *format == formatText && isTerminal(os.Stdout) {
+		if !confirmLarge(opts.sized(img.Bounds()).layout(img.Bounds()), *warnLarge) {
+			fmt.Fprintf(os.Stderr, "Aborted\n")
+			os.Exit(1)
+		}
+	}
+
+	// Generate ASCII art based on the selected rendering mode
+	stopProfiling := mustStartProfiling(*cpuProfile, *memProfile)
+	if *splitChannelsFlag {
+		splitChannels(img, opts, *splitLayout, emit)
 	} else {
-		art = toASCII(img, *width, *scale)
+		opts.render(img, emit)
+	}
+	stopProfiling()
+
+	if !*chunkOutput {
+		writeOutput(art, out)
+	} else if err := chunkErr(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write output: %v\n", err)
+		os.Exit(1)
 	}
+	out.notifyDone()
+}
 
-	// Output to file or stdout
-	if *save != "" {
-		// Write to file
-		output := strings.Join(art, "\n") + "\n"
-		err := os.WriteFile(*save, []byte(output), 0644)
+// notifyDone signals that processing has finished, if requested. The bell and
+// OSC 9 sequence go to stderr so they never end up in redirected art.
+func (o outputOptions) notifyDone() {
+	if !o.notify || o.quiet {
+		return
+	}
+	if o.notifyOSC {
+		// Format: ESC ] 9 ; <message> BEL
+		fmt.Fprint(os.Stderr, "\x1b]9;pixelterm: rendering finished\a")
+	}
+	fmt.Fprint(os.Stderr, "\a")
+}
+
+// writeOutput overlays the caption, if any, then encodes the rendered lines in
+// the requested format and writes them to the save path, or to stdout when no
+// path is given.
+func writeOutput(art []string, out outputOptions) {
+	art = overlayCaption(art, out.caption, out.captionPos, out.captionEscape)
+
+	// Rewritten after the caption, which is centered on the full row width
+	// and placed by replacing self-contained cells. Only the text formats are
+	// rewritten, so raster grids keep their full width
+	textual := out.format == formatText || out.format == formatANS
+	trim := out.trimTrailing && textual
+	compact := out.compactColor && textual
+	if trim || compact {
+		rewritten := make([]string, len(art))
+		for i, line := range art {
+			if trim {
+				line = trimTrailingSpace(line)
+			}
+			if compact {
+				line = compactEscapes(line)
+			}
+			rewritten[i] = line
+		}
+		art = rewritten
+	}
+
+	// Encoded formats are produced as a whole and written as-is
+	if out.format != formatText {
+		data, err := encodeOutput(art, out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to encode %s output: %v\n", out.format, err)
+			os.Exit(1)
+		}
+
+		if out.save == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(out.save, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to write to file '%s': %v\n", out.save, err)
+			os.Exit(1)
+		}
+		if !out.quiet {
+			fmt.Printf("%s output saved to '%s'\n", strings.ToUpper(out.format), out.save)
+		}
+		return
+	}
+
+	// Output to file or stdout, in both cases as a single write
+	if out.save != "" {
+		// Write to file, with the line ending and BOM chosen for saved text
+		output := joinLines(art, out.lineEnding)
+		if out.bom {
+			output = utf8BOM + output
+		}
+		err := os.WriteFile(out.save, []byte(output), 0644)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to write to file '%s': %v\n", *save, err)
+			fmt.Fprintf(os.Stderr, "Error: Failed to write to file '%s': %v\n", out.save, err)
 			os.Exit(1)
 		}
-		fmt.Printf("ASCII art saved to '%s'\n", *save)
+		if !out.quiet {
+			fmt.Printf("ASCII art saved to '%s'\n", out.save)
+		}
+	} else if _, err := io.WriteString(os.Stdout, joinLines(art, "\n")); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write output: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// joinLines joins rendered lines into a single block of text in which every
+// line, including the last, ends with the given line ending.
+func joinLines(art []string, ending string) string {
+	if len(art) == 0 {
+		return ""
+	}
+	return strings.Join(art, ending) + ending
+}
+
+// encodeOutput serializes the art in one of the non-text formats.
+func encodeOutput(art []string, out outputOptions) ([]byte, error) {
+	switch out.format {
+	case formatGo:
+		return []byte(goSource(art, out.varName)), nil
+	case formatANS:
+		return ansFile(art, out.title, out.author), nil
+	}
+
+	rf := outputFont(out)
+
+	var buf bytes.Buffer
+	var err error
+	if out.format == formatPNG {
+		err = writePNG(&buf, art, rf)
 	} else {
-		// Print to stdout
-		for _, line := range art {
-			fmt.Println(line)
+		err = writeSVG(&buf, art, rf)
+	}
+	return buf.Bytes(), err
+}
+
+// render converts an image with the renderer selected by the options,
+// passing each finished row to emit in order. Half-block requests fall back
+// to colored characters in -safe-ascii mode, since -halfblock implies color.
+func (o options) render(img image.Image, emit func(line string)) {
+	o = o.sized(img.Bounds()).prepareCurve(img)
+
+	switch {
+	case o.halfBlock && !o.safeASCII:
+		halfBlockASCII(img, o, emit)
+	case o.color || o.halfBlock:
+		colorASCII(img, o, emit)
+	default:
+		toASCII(img, o, emit)
+	}
+}
+
+// sized resolves the per-image grid size settings in order: the cell budget,
+// the -downscale-only width limit, the aspect clamp and the height limit.
+func (o options) sized(bounds image.Rectangle) options {
+	return o.budgeted(bounds).clampWidth(bounds).clampAspect().fitHeight(bounds)
+}
+
+// fitHeight narrows the width until the aspect-correct height fits within the
+// requested number of rows, so -width and -height together describe a box the
+// image is fitted into. Stretched renders use both sizes as-is.
+func (o options) fitHeight(bounds image.Rectangle) options {
+	if o.height > 0 && !o.stretch && outputHeight(bounds, o.width, o.scale) > o.height {
+		width := max(1, int(float64(o.height)*float64(bounds.Dx())/(float64(bounds.Dy())*o.scale)))
+
+		// Heights are truncated, so wider grids may still fit the box
+		for width < o.width && outputHeight(bounds, width+1, o.scale) <= o.height {
+			width++
 		}
+		o.width = width
 	}
+	return o
 }
 
-// toASCII converts an image to ASCII art with the specified output width and scale.
-// The aspect ratio is preserved, accounting for typical terminal character height.
-// Uses goroutines to parallelize row processing for improved performance.
-func toASCII(img image.Image, width int, scale float64) []string {
-	// ASCII palette from dark to light
-	palette := "@%#*+=-:. "
+// budgeted derives the output width from the cell budget, when one is set,
+// now that the image's aspect ratio is known.
+func (o options) budgeted(bounds image.Rectangle) options {
+	if o.cellBudget > 0 {
+		o.width = budgetWidth(bounds, o.cellBudget, o.scale)
+	}
+	return o
+}
 
-	bounds := img.Bounds()
-	imgWidth := bounds.Dx()
-	imgHeight := bounds.Dy()
+// parseGridSize validates the -width and -height settings and parses the
+// -tile grid, which is 0x0 when no tile size is given. The width divides the
+// image into cells, so it must be at least 1 even when a tile grid is set.
+func parseGridSize(width, height int, tile string) (tileCols, tileRows int, err error) {
+	if width < 1 {
+		return 0, 0, fmt.Errorf("Invalid width %d (must be at least 1)", width)
+	}
+	if height < 0 {
+		return 0, 0, fmt.Errorf("Invalid height %d (must be 0 or positive)", height)
+	}
+	if tile != "" {
+		if _, err := fmt.Sscanf(tile, "%dx%d", &tileCols, &tileRows); err != nil || tileCols < 1 || tileRows < 1 {
+			return 0, 0, fmt.Errorf("Invalid tile size '%s' (expected WxH, e.g. 200x40)", tile)
+		}
+	}
+	return tileCols, tileRows, nil
+}
+
+// reportClamp prints a note when -downscale-only narrows the width or
+// -clamp-aspect limits the height scale that would otherwise be used for an
+// image, and warns when the output collapses to a single row.
+func reportClamp(opts options, bounds image.Rectangle, quiet bool) {
+	if quiet {
+		return
+	}
+
+	requested := opts.budgeted(bounds)
+	clamped := requested.clampWidth(bounds)
+	if clamped.width != requested.width {
+		fmt.Fprintf(os.Stderr, "Note: Clamping width from %d to %d, the source image width (-downscale-only)\n", requested.width, clamped.width)
+	}
+
+	limited := clamped.clampAspect()
+	if limited.scale != clamped.scale {
+		fmt.Fprintf(os.Stderr, "Note: Clamping scale from %g to %g, within %gx of the aspect-correct %g (-clamp-aspect)\n",
+			clamped.scale, limited.scale, opts.aspectLimit, opts.cellRatio)
+	}
+
+	// outputHeight only guards against a zero height, so call out the sliver
+	exact := float64(bounds.Dy()) * float64(limited.width) / float64(bounds.Dx()) * limited.scale
+	if exact < 1 && !opts.stretch {
+		fmt.Fprintf(os.Stderr, "Warning: Output height clamped to 1 row (computed %.2f); the aspect-correct -scale is about %g\n", exact, opts.cellRatio)
+	}
+}
+
+// confirmLarge asks on the terminal whether to go ahead with a render of more
+// than limit cells, printing its dimensions. It returns true without asking
+// for smaller renders and when no terminal can be opened to ask on.
+func confirmLarge(g grid, limit int) bool {
+	if g.cols*g.rows <= limit {
+		return true
+	}
+	tty, err := openTerminal()
+	if err != nil {
+		return true
+	}
+	defer tty.Close()
 
-	// Calculate output height with character aspect ratio correction and scale
-	height := int(float64(imgHeight) * float64(width) / float64(imgWidth) * scale)
+	fmt.Fprintf(tty, "Output will be %dx%d (%d cells, over -warn-large %d). Continue? [y/N] ", g.cols, g.rows, g.cols*g.rows, limit)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// transparentCell returns the formatted -transparent-as replacement for a
+// cell whose average alpha is below the threshold, and false for opaque cells
+// or when the option is disabled. Colored output draws it in the transparent
+// color so masks stay visible without hiding the character.
+func (o options) transparentCell(img image.Image, x0, y0, x1, y1 int) (string, bool) {
+	if o.transparentChar == "" || blockAlpha(img, x0, y0, x1, y1) >= o.alphaThreshold {
+		return "", false
+	}
+	if !o.color && !o.halfBlock {
+		return o.transparentChar, true
+	}
+	c := o.transparentColor
+	return fgEscape(c[0], c[1], c[2], o.colorMode, o.colorDistance) + o.transparentChar + ansiReset, true
+}
+
+// clampAspect limits the height scale to within aspectLimit times the
+// aspect-correct cell ratio in either direction, so a stray -scale value
+// cannot squash or stretch the render beyond recognition.
+func (o options) clampAspect() options {
+	if o.aspectLimit > 0 {
+		o.scale = math.Min(math.Max(o.scale, o.cellRatio/o.aspectLimit), o.cellRatio*o.aspectLimit)
+	}
+	return o
+}
+
+// clampWidth limits the output width to the source width in -downscale-only
+// mode, so small images render at native resolution instead of repeating
+// pixels. The height follows since it is derived from the width.
+func (o options) clampWidth(bounds image.Rectangle) options {
+	if o.downscaleOnly && o.width > bounds.Dx() {
+		o.width = bounds.Dx()
+	}
+	return o
+}
+
+// outputHeight calculates the number of output rows for the given image bounds
+// and output width, applying the character aspect ratio correction and scale.
+func outputHeight(bounds image.Rectangle, width int, scale float64) int {
+	height := int(float64(bounds.Dy()) * float64(width) / float64(bounds.Dx()) * scale)
 
 	// Prevent division by zero
 	if height == 0 {
 		height = 1
 	}
+	return height
+}
 
-	result := make([]string, height)
+// budgetWidth returns the output width whose aspect-corrected grid holds
+// approximately budget cells. Since height = width * aspect * scale, the cell
+// count is width² * aspect * scale, which is solved here for width.
+func budgetWidth(bounds image.Rectangle, budget int, scale float64) int {
+	aspect := float64(bounds.Dy()) / float64(bounds.Dx())
+	width := int(math.Sqrt(float64(budget) / (aspect * scale)))
 
-	// Type to hold processed row results with original index for ordering
-	type rowResult struct {
-		index int
-		line  string
+	// Always render at least one column
+	if width < 1 {
+		width = 1
 	}
+	return width
+}
 
-	// Buffered channel to collect results from worker goroutines
-	// Buffer size equals height to prevent blocking
-	resultChan := make(chan rowResult, height)
+// grid describes how output cells map onto the source image. A single copy of
+// the image spans tileCols x tileRows cells, while the output is cols x rows
+// cells; when the output is larger the image repeats.
+type grid struct {
+	cols, rows          int
+	tileCols, tileRows  int
+	imgWidth, imgHeight int
+}
 
-	// Process each row in parallel using goroutines
-	for y := 0; y < height; y++ {
-		go func(rowIndex int) {
-			line := ""
-			
-			// Calculate source image row boundaries for this output row
-			imgY := rowIndex * imgHeight / height
-			imgYEnd := (rowIndex + 1) * imgHeight / height
-			if imgYEnd > imgHeight {
-				imgYEnd = imgHeight
-			}
+// layout computes the output grid for an image with the given bounds. The
+// image is rendered at the requested width with aspect-corrected height, and
+// that copy is repeated to fill the -tile size when one is set. Stretched
+// renders take the requested height instead of the aspect-correct one.
+func (o options) layout(bounds image.Rectangle) grid {
+	g := grid{
+		tileCols:  o.width,
+		tileRows:  outputHeight(bounds, o.width, o.scale),
+		imgWidth:  bounds.Dx(),
+		imgHeight: bounds.Dy(),
+	}
+	if o.stretch {
+		g.tileRows = o.height
+	}
+	g.cols, g.rows = g.tileCols, g.tileRows
+	if o.tileCols > 0 && o.tileRows > 0 {
+		g.cols, g.rows = o.tileCols, o.tileRows
+	}
+	return g
+}
 
-			for x := 0; x < width; x++ {
-				// Calculate source image column boundaries for this character
-				imgX := x * imgWidth / width
-				imgXEnd := (x + 1) * imgWidth / width
-				if imgXEnd > imgWidth {
-					imgXEnd = imgWidth
-				}
-
-				// Sample block average instead of single pixel
-				var rSum, gSum, bSum uint64
-				pixelCount := 0
-
-				// Sample the block with stride to avoid processing every pixel
-				// Use stride of max(1, blockWidth/3) to get representative samples
-				strideX := (imgXEnd - imgX) / 3
-				if strideX < 1 {
-					strideX = 1
-				}
-				strideY := (imgYEnd - imgY) / 3
-				if strideY < 1 {
-					strideY = 1
-				}
-
-				for py := imgY; py < imgYEnd; py += strideY {
-					for px := imgX; px < imgXEnd; px += strideX {
-						r, g, b, _ := img.At(px, py).RGBA()
-						rSum += uint64(r)
-						gSum += uint64(g)
-						bSum += uint64(b)
-						pixelCount++
-					}
-				}
-
-				// Calculate average color
-				if pixelCount > 0 {
-					rSum /= uint64(pixelCount)
-					gSum /= uint64(pixelCount)
-					bSum /= uint64(pixelCount)
-				}
-
-				// Convert to grayscale using standard luminance formula
-				gray := (299*rSum + 587*gSum + 114*bSum) / 1000 / 256
-
-				// Map brightness to ASCII character
-				charIndex := int(gray) * (len(palette) - 1) / 255
-				line += string(palette[charIndex])
-			}
+// span returns the source pixel range [start, end) covered by cell i when size
+// pixels are divided into cells cells. Cells past the end of the image map
+// beyond size and are wrapped back into range by sampleBlock.
+func span(i, cells, size int) (int, int) {
+	return i * size / cells, (i + 1) * size / cells
+}
 
-			// Send result with index to preserve order
-			resultChan <- rowResult{index: rowIndex, line: line}
-		}(y)
+// sampleBlock returns the average 16-bit color of the source block spanning
+// [x0, x1) x [y0, y1), relative to the image origin. Empty blocks (which occur
+// when upscaling) are widened to a single pixel so they repeat their neighbor.
+// Coordinates wrap modulo the image size so tiled grids repeat the source.
+func sampleBlock(img image.Image, x0, y0, x1, y1 int) (r, g, b uint64) {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
 	}
 
-	// Collect results from all goroutines
-	for i := 0; i < height; i++ {
-		res := <-resultChan
-		result[res.index] = res.line
+	// Sample the block with stride to avoid processing every pixel
+	// Use stride of max(1, blockWidth/3) to get representative samples
+	strideX := (x1 - x0) / 3
+	if strideX < 1 {
+		strideX = 1
+	}
+	strideY := (y1 - y0) / 3
+	if strideY < 1 {
+		strideY = 1
 	}
 
-	close(resultChan)
+	bounds := img.Bounds()
+	var rSum, gSum, bSum uint64
+	pixelCount := 0
+	for py := y0; py < y1; py += strideY {
+		for px := x0; px < x1; px += strideX {
+			sx := bounds.Min.X + px%bounds.Dx()
+			sy := bounds.Min.Y + py%bounds.Dy()
+			pr, pg, pb, _ := img.At(sx, sy).RGBA()
+			rSum += uint64(pr)
+			gSum += uint64(pg)
+			bSum += uint64(pb)
+			pixelCount++
+		}
+	}
 
-	return result
+	// Calculate average color
+	if pixelCount > 0 {
+		rSum /= uint64(pixelCount)
+		gSum /= uint64(pixelCount)
+		bSum /= uint64(pixelCount)
+	}
+	return rSum, gSum, bSum
 }
 
-// colorASCII converts an image to colored ASCII art using truecolor ANSI escapes.
-// Character selection is based on grayscale, but colors are preserved from the original image.
-// Uses goroutines to parallelize row processing for improved performance.
-func colorASCII(img image.Image, width int, scale float64) []string {
-	// ASCII palette from dark to light
-	palette := "@%#*+=-:. "
+// luminance converts a 16-bit color to an 8-bit grayscale value using the
+// standard luminance formula.
+func luminance(r, g, b uint64) uint64 {
+	return (299*r + 587*g + 114*b) / 1000 / 256
+}
+
+// paletteChar maps an 8-bit brightness value to its character in the ramp.
+func paletteChar(ramp string, gray uint64) byte {
+	return ramp[int(gray)*(len(ramp)-1)/255]
+}
+
+// sampleAlphaWeighted returns the average straight (non-premultiplied) 16-bit
+// color of a source block, weighting each sample by its alpha. image.Color
+// values are premultiplied, so summing them and dividing by the total alpha
+// recovers the color of the opaque samples instead of darkening it toward
+// black; fully transparent blocks come out black.
+func sampleAlphaWeighted(img image.Image, x0, y0, x1, y1 int) (r, g, b uint64) {
+	var rSum, gSum, bSum, aSum uint64
+	forEachSample(img, x0, y0, x1, y1, 3, func(pr, pg, pb, pa uint32) {
+		rSum += uint64(pr)
+		gSum += uint64(pg)
+		bSum += uint64(pb)
+		aSum += uint64(pa)
+	})
+
+	if aSum == 0 {
+		return 0, 0, 0
+	}
+	return rSum * 0xffff / aSum, gSum * 0xffff / aSum, bSum * 0xffff / aSum
+}
+
+// sampleColor returns the averaged color of the source region of the cell at
+// column col of grid gr, weighted by alpha in -alpha-weighted mode and
+// composited over the checkerboard in -checker-bg mode. The cell's rows are
+// given in half-cell units, the resolution of the half-block renderer: a full
+// cell starts at halfRow 2*row and spans 2 half rows. Checker squares are
+// placed in the same units so they stay square whichever renderer is used.
+func (o options) sampleColor(img image.Image, gr grid, col, halfRow, halfRows int) (r, g, b uint64) {
+	if o.sampling == samplingArea {
+		return o.sampleArea(img, gr, col, halfRow, halfRows)
+	}
+
+	x0, x1 := span(col, gr.tileCols, gr.imgWidth)
+	y0, _ := span(halfRow, 2*gr.tileRows, gr.imgHeight)
+	_, y1 := span(halfRow+halfRows-1, 2*gr.tileRows, gr.imgHeight)
+	if o.checkerSize > 0 {
+		return o.overChecker(img, col, halfRow, x0, y0, x1, y1)
+	}
+	if o.alphaWeighted {
+		return sampleAlphaWeighted(img, x0, y0, x1, y1)
+	}
+	return sampleBlock(img, x0, y0, x1, y1)
+}
+
+// Checkerboard colors drawn behind transparency in -checker-bg mode, as in
+// image editors.
+const (
+	checkerLight = 0xffff
+	checkerDark  = 0xcccc
+)
+
+// overChecker composites the samples of a source block over the checkerboard
+// square the cell falls in. Squares are checkerSize columns wide and, since a
+// cell is about twice as tall as it is wide, checkerSize half rows tall.
+// Summing the premultiplied samples gives the "over" result directly: the
+// average color plus the checker color scaled by the missing alpha.
+func (o options) overChecker(img image.Image, col, halfRow, x0, y0, x1, y1 int) (r, g, b uint64) {
+	var rSum, gSum, bSum, aSum uint64
+	n := uint64(0)
+	forEachSample(img, x0, y0, x1, y1, 3, func(pr, pg, pb, pa uint32) {
+		rSum += uint64(pr)
+		gSum += uint64(pg)
+		bSum += uint64(pb)
+		aSum += uint64(pa)
+		n++
+	})
+
+	behind := o.checkerShade(col, halfRow) * (0xffff - aSum/n) / 0xffff
+	return rSum/n + behind, gSum/n + behind, bSum/n + behind
+}
+
+// checkerShade returns the checkerboard color of the square containing the
+// given cell column and half row.
+func (o options) checkerShade(col, halfRow int) uint64 {
+	if (col/o.checkerSize+halfRow/o.checkerSize)%2 == 1 {
+		return checkerDark
+	}
+	return checkerLight
+}
+
+// blockStdDev returns the standard deviation of the 8-bit luminance of the
+// samples in a source block, using the same sampling grid as sampleBlock.
+// It measures how much detail a cell contains.
+func blockStdDev(img image.Image, x0, y0, x1, y1 int) float64 {
+	var sum, sumSq float64
+	n := 0
+	forEachSample(img, x0, y0, x1, y1, 3, func(r, g, b, _ uint32) {
+		v := float64(luminance(uint64(r), uint64(g), uint64(b)))
+		sum += v
+		sumSq += v * v
+		n++
+	})
+
+	mean := sum / float64(n)
+	return math.Sqrt(math.Max(0, sumSq/float64(n)-mean*mean))
+}
+
+// blockAlpha returns the average 8-bit alpha of the samples in a source block.
+func blockAlpha(img image.Image, x0, y0, x1, y1 int) uint64 {
+	var sum uint64
+	n := 0
+	forEachSample(img, x0, y0, x1, y1, 3, func(_, _, _, a uint32) {
+		sum += uint64(a)
+		n++
+	})
+	return sum / uint64(n) >> 8
+}
+
+// forEachSample calls fn with the 16-bit RGBA value of every pixel on a
+// density×density sampling grid of a source block. A density of 3 visits the
+// same pixels as sampleBlock. Used by the per-cell statistics that are only
+// computed when enabled.
+func forEachSample(img image.Image, x0, y0, x1, y1, density int, fn func(r, g, b, a uint32)) {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+
+	strideX := (x1 - x0) / density
+	if strideX < 1 {
+		strideX = 1
+	}
+	strideY := (y1 - y0) / density
+	if strideY < 1 {
+		strideY = 1
+	}
 
 	bounds := img.Bounds()
-	imgWidth := bounds.Dx()
-	imgHeight := bounds.Dy()
+	for py := y0; py < y1; py += strideY {
+		for px := x0; px < x1; px += strideX {
+			sx := bounds.Min.X + px%bounds.Dx()
+			sy := bounds.Min.Y + py%bounds.Dy()
+			fn(img.At(sx, sy).RGBA())
+		}
+	}
+}
 
-	// Calculate output height with character aspect ratio correction and scale
-	height := int(float64(imgHeight) * float64(width) / float64(imgWidth) * scale)
+// previewPalette renders the palette across a synthetic black-to-white gradient
+// bar of the given width, so the ramp can be judged without an image. The first
+// line lists the ramp itself; in color mode a colored bar follows the plain one.
+func previewPalette(opts options) []string {
+	width := opts.width
+	lines := []string{fmt.Sprintf("Palette: %q (%d characters)", palette, len(palette))}
+	if opts.rampMap != nil {
+		chars := opts.rampMap.chars()
+		lines[0] = fmt.Sprintf("Ramp map: %q (%d ranges)", chars, len(chars))
+	}
 
-	// Prevent division by zero
-	if height == 0 {
-		height = 1
+	plain := ""
+	colored := ""
+	for x := 0; x < width; x++ {
+		// Brightness increases linearly from 0 at the left to 255 at the right
+		gray := uint64(255)
+		if width > 1 {
+			gray = uint64(x * 255 / (width - 1))
+		}
+
+		char := string(opts.charFor(palette, gray))
+		plain += char
+		colored += fgEscape(uint8(gray), uint8(gray), uint8(gray), opts.colorMode, opts.colorDistance) + char + ansiReset
 	}
 
-	result := make([]string, height)
+	lines = append(lines, plain)
+	if opts.color {
+		lines = append(lines, colored)
+	}
+	return lines
+}
 
+// renderRows renders height rows with a pool of renderJobs workers and passes
+// them to emit in order. Rows that finish early are buffered until every row
+// before them has been emitted, and at most rowWindow rows are in flight at
+// once.
+func renderRows(height int, renderRow func(row int) string, emit func(line string)) {
 	// Type to hold processed row results with original index for ordering
 	type rowResult struct {
 		index int
@@ -214,90 +1404,184 @@ func colorASCII(img image.Image, width int, scale float64) []string {
 	}
 
 	// Buffered channel to collect results from worker goroutines
-	// Buffer size equals height to prevent blocking
-	resultChan := make(chan rowResult, height)
+	resultChan := make(chan rowResult, rowWindow)
 
-	// Process each row in parallel using goroutines
-	for y := 0; y < height; y++ {
-		go func(rowIndex int) {
-			line := ""
-			
-			// Calculate source image row boundaries for this output row
-			imgY := rowIndex * imgHeight / height
-			imgYEnd := (rowIndex + 1) * imgHeight / height
-			if imgYEnd > imgHeight {
-				imgYEnd = imgHeight
-			}
+	// Each in-flight row holds a token until it has been emitted
+	tokens := make(chan struct{}, rowWindow)
 
-			for x := 0; x < width; x++ {
-				// Calculate source image column boundaries for this character
-				imgX := x * imgWidth / width
-				imgXEnd := (x + 1) * imgWidth / width
-				if imgXEnd > imgWidth {
-					imgXEnd = imgWidth
-				}
-
-				// Sample block average instead of single pixel
-				var rSum, gSum, bSum uint64
-				pixelCount := 0
-
-				// Sample the block with stride to avoid processing every pixel
-				// Use stride of max(1, blockWidth/3) to get representative samples
-				strideX := (imgXEnd - imgX) / 3
-				if strideX < 1 {
-					strideX = 1
-				}
-				strideY := (imgYEnd - imgY) / 3
-				if strideY < 1 {
-					strideY = 1
-				}
-
-				for py := imgY; py < imgYEnd; py += strideY {
-					for px := imgX; px < imgXEnd; px += strideX {
-						r, g, b, _ := img.At(px, py).RGBA()
-						rSum += uint64(r)
-						gSum += uint64(g)
-						bSum += uint64(b)
-						pixelCount++
-					}
-				}
-
-				// Calculate average color
-				if pixelCount > 0 {
-					rSum /= uint64(pixelCount)
-					gSum /= uint64(pixelCount)
-					bSum /= uint64(pixelCount)
-				}
-
-				// Convert to 8-bit RGB values
-				r8 := uint8(rSum >> 8)
-				g8 := uint8(gSum >> 8)
-				b8 := uint8(bSum >> 8)
-
-				// Convert to grayscale for character selection
-				gray := (299*rSum + 587*gSum + 114*bSum) / 1000 / 256
-
-				// Map brightness to ASCII character
-				charIndex := int(gray) * (len(palette) - 1) / 255
-				char := palette[charIndex]
-
-				// Build colored character with ANSI truecolor escape
-				// Format: \x1b[38;2;<r>;<g>;<b>m<char>\x1b[0m
-				line += fmt.Sprintf("\x1b[38;2;%d;%d;%dm%c\x1b[0m", r8, g8, b8, char)
-			}
+	// Hand out row indices in order to the workers
+	rows := make(chan int)
+	go func() {
+		for y := 0; y < height; y++ {
+			tokens <- struct{}{}
+			rows <- y
+		}
+		close(rows)
+	}()
 
-			// Send result with index to preserve order
-			resultChan <- rowResult{index: rowIndex, line: line}
-		}(y)
+	for w := 0; w < renderJobs; w++ {
+		go func() {
+			for rowIndex := range rows {
+				resultChan <- rowResult{index: rowIndex, line: renderRow(rowIndex)}
+			}
+		}()
 	}
 
-	// Collect results from all goroutines
-	for i := 0; i < height; i++ {
+	// Emit results in order, holding back rows that arrive early
+	pending := make(map[int]string)
+	for next := 0; next < height; {
 		res := <-resultChan
-		result[res.index] = res.line
+		pending[res.index] = res.line
+		for line, ok := pending[next]; ok; line, ok = pending[next] {
+			delete(pending, next)
+			emit(line)
+			<-tokens
+			next++
+		}
 	}
+}
 
-	close(resultChan)
+// forEachRow calls fn for every row index below height using renderJobs
+// workers and returns once all calls have finished. Rows are handed out in
+// order but may complete in any order.
+func forEachRow(height int, fn func(row int)) {
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < renderJobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rowIndex := range rows {
+				fn(rowIndex)
+			}
+		}()
+	}
+
+	for y := 0; y < height; y++ {
+		rows <- y
+	}
+	close(rows)
+	wg.Wait()
+}
+
+// toASCII converts an image to ASCII art with the specified output width and scale.
+// The aspect ratio is preserved, accounting for typical terminal character height.
+// Rows are rendered in parallel and passed to emit in order.
+func toASCII(img image.Image, opts options, emit func(line string)) {
+	// Calculate the output grid with character aspect ratio correction and scale
+	gr := opts.layout(img.Bounds())
+
+	renderRows(gr.rows, func(rowIndex int) string {
+		line := ""
+
+		// Calculate source image row boundaries for this output row
+		imgY, imgYEnd := span(rowIndex, gr.tileRows, gr.imgHeight)
+
+		for x := 0; x < gr.cols; x++ {
+			// Calculate source image column boundaries for this character
+			imgX, imgXEnd := span(x, gr.tileCols, gr.imgWidth)
+
+			// Transparent cells are drawn verbatim without sampling color
+			if cell, ok := opts.transparentCell(img, imgX, imgY, imgXEnd, imgYEnd); ok {
+				line += cell
+				continue
+			}
+
+			// Sample block average instead of single pixel
+			r, g, b := opts.applyGains(opts.sampleColor(img, gr, x, 2*rowIndex, 2))
+
+			// Map brightness to ASCII character
+			ramp := opts.rampFor(img, imgX, imgY, imgXEnd, imgYEnd)
+			gray := opts.charGray(img, imgX, imgY, imgXEnd, imgYEnd, opts.adjustGray(opts.monoLuma(r, g, b)))
+			line += string(opts.charFor(ramp, opts.scanlineGray(rowIndex, gray)))
+		}
+
+		return line
+	}, emit)
+}
+
+// colorASCII converts an image to colored ASCII art using ANSI color escapes.
+// Character selection is based on grayscale, but colors are preserved from the original image.
+// The color mode selects truecolor escapes or the nearest xterm-256 palette entry.
+// Rows are rendered in parallel and passed to emit in order.
+func colorASCII(img image.Image, opts options, emit func(line string)) {
+	// Calculate the output grid with character aspect ratio correction and scale
+	gr := opts.layout(img.Bounds())
+
+	renderRows(gr.rows, func(rowIndex int) string {
+		line := ""
+
+		// Calculate source image row boundaries for this output row
+		imgY, imgYEnd := span(rowIndex, gr.tileRows, gr.imgHeight)
+
+		for x := 0; x < gr.cols; x++ {
+			// Calculate source image column boundaries for this character
+			imgX, imgXEnd := span(x, gr.tileCols, gr.imgWidth)
+
+			// Transparent cells are drawn verbatim without sampling color
+			if cell, ok := opts.transparentCell(img, imgX, imgY, imgXEnd, imgYEnd); ok {
+				line += cell
+				continue
+			}
+
+			// Sample block average instead of single pixel
+			r, g, b := opts.adjustColor(opts.sampleColor(img, gr, x, 2*rowIndex, 2))
+
+			// Map brightness to ASCII character
+			ramp := opts.rampFor(img, imgX, imgY, imgXEnd, imgYEnd)
+			char := opts.charFor(ramp, opts.charGray(img, imgX, imgY, imgXEnd, imgYEnd, luminance(r, g, b)))
+
+			// Build colored character with an ANSI foreground escape
+			// Format: <escape><char>\x1b[0m
+			r, g, b = opts.scanlineColor(rowIndex, r, g, b)
+			line += fgEscape(uint8(r>>8), uint8(g>>8), uint8(b>>8), opts.colorMode, opts.colorDistance) + string(char) + ansiReset
+		}
+
+		return line
+	}, emit)
+}
+
+// halfBlockASCII renders an image at double vertical resolution using the upper
+// half block character: the foreground color carries the top pixel row of each
+// cell and the background color carries the bottom one. The color mode selects
+// truecolor escapes or the nearest xterm-256 palette entry for both colors.
+// Rows are rendered in parallel and passed to emit in order.
+func halfBlockASCII(img image.Image, opts options, emit func(line string)) {
+	// Each character row covers two sub-rows of the source image
+	gr := opts.layout(img.Bounds())
+	subRows := gr.tileRows * 2
+
+	renderRows(gr.rows, func(rowIndex int) string {
+		line := ""
+
+		// Calculate source image boundaries for the whole cell; each half is
+		// sampled by sampleColor
+		topY, _ := span(2*rowIndex, subRows, gr.imgHeight)
+		_, bottomY := span(2*rowIndex+1, subRows, gr.imgHeight)
+
+		for x := 0; x < gr.cols; x++ {
+			// Calculate source image column boundaries for this character
+			imgX, imgXEnd := span(x, gr.tileCols, gr.imgWidth)
+
+			// Transparent cells are drawn verbatim without sampling color
+			if cell, ok := opts.transparentCell(img, imgX, topY, imgXEnd, bottomY); ok {
+				line += cell
+				continue
+			}
+
+			tr, tg, tb := opts.adjustColor(opts.sampleColor(img, gr, x, 2*rowIndex, 1))
+			br, bg, bb := opts.adjustColor(opts.sampleColor(img, gr, x, 2*rowIndex+1, 1))
+
+			// The bottom half is always the odd pixel row for scanlines
+			tr, tg, tb = opts.scanlineColor(2*rowIndex, tr, tg, tb)
+			br, bg, bb = opts.scanlineColor(2*rowIndex+1, br, bg, bb)
+
+			// Format: <fg escape><bg escape>▀\x1b[0m
+			line += fgEscape(uint8(tr>>8), uint8(tg>>8), uint8(tb>>8), opts.colorMode, opts.colorDistance) +
+				bgEscape(uint8(br>>8), uint8(bg>>8), uint8(bb>>8), opts.colorMode, opts.colorDistance) +
+				upperHalfBlock + ansiReset
+		}
 
-	return result
+		return line
+	}, emit)
 }
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestParseGridSize(t *testing.T) {
+	for _, c := range []struct {
+		width, height int
+		tile          string
+		cols, rows    int
+		ok            bool
+	}{
+		{100, 0, "", 0, 0, true},
+		{10, 0, "200x40", 200, 40, true},
+		{0, 0, "10x3", 0, 0, false},
+		{0, 0, "", 0, 0, false},
+		{10, -1, "", 0, 0, false},
+		{10, 0, "10", 0, 0, false},
+		{10, 0, "0x3", 0, 0, false},
+	} {
+		cols, rows, err := parseGridSize(c.width, c.height, c.tile)
+		if (err == nil) != c.ok || cols != c.cols || rows != c.rows {
+			t.Errorf("parseGridSize(%d, %d, %q) = %d, %d, %v", c.width, c.height, c.tile, cols, rows, err)
+		}
+	}
+}
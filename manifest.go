@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// manifestEntry is one image listed in a manifest, with the options that
+// result from merging its overrides into the command-line settings.
+type manifestEntry struct {
+	line int    // 1-based line number in the manifest
+	path string // image path or URL
+	opts options
+	out  outputOptions
+}
+
+// parseManifest reads a manifest file and validates every entry up front, so
+// a typo on the last line is reported before any image is processed. Each
+// non-empty line that does not start with '#' holds a path or URL followed by
+// optional key=value overrides named after the command-line flags, e.g.
+//
+//	photos/cat.jpg width=80 color=false save=cat.txt
+//
+// Relative paths are resolved against the manifest's directory.
+func parseManifest(path string, base options, baseOut outputOptions) ([]manifestEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dir := filepath.Dir(path)
+	var entries []manifestEntry
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		entry := manifestEntry{line: lineNum, path: fields[0], opts: base, out: baseOut}
+		if !isURL(entry.path) {
+			if !filepath.IsAbs(entry.path) {
+				entry.path = filepath.Join(dir, entry.path)
+			}
+			if _, err := os.Stat(entry.path); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+			}
+		}
+
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: override '%s' is not in key=value form", path, lineNum, field)
+			}
+			if err := entry.applyOverride(key, value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %s: %v", path, lineNum, key, err)
+			}
+		}
+
+		// Overrides can conflict with each other or with the command line
+		o := entry.opts
+		if err := checkToneCurves(o.equalize, o.autoContrast, o.blackPoint, o.whitePoint); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: no entries", path)
+	}
+	return entries, nil
+}
+
+// applyOverride sets a single per-image option from a manifest override.
+func (e *manifestEntry) applyOverride(key, value string) error {
+	var err error
+	switch key {
+	case "width":
+		e.opts.width, err = parsePositiveInt(value)
+	case "height":
+		e.opts.height, err = parsePositiveInt(value)
+	case "scale":
+		e.opts.scale, err = parsePositiveFloat(value)
+	case "color":
+		e.opts.color, err = strconv.ParseBool(value)
+	case "halfblock":
+		e.opts.halfBlock, err = strconv.ParseBool(value)
+	case "colormode":
+		if value != colorModeTrue && value != colorMode256 {
+			return fmt.Errorf("expected %s or %s", colorModeTrue, colorMode256)
+		}
+		e.opts.colorMode = value
+	case "color-distance":
+		switch value {
+		case distanceRGB, distanceCIE76, distanceWeighted:
+			e.opts.colorDistance = value
+		default:
+			return fmt.Errorf("expected %s, %s or %s", distanceRGB, distanceCIE76, distanceWeighted)
+		}
+	case "sampling":
+		if value != samplingBlock && value != samplingArea {
+			return fmt.Errorf("expected %s or %s", samplingBlock, samplingArea)
+		}
+		e.opts.sampling = value
+	case "negative":
+		e.opts.negative, err = strconv.ParseBool(value)
+	case "solarize":
+		e.opts.solarize, err = strconv.Atoi(value)
+		if err == nil && (e.opts.solarize < 0 || e.opts.solarize > 255) {
+			return fmt.Errorf("expected 0-255")
+		}
+	case "posterize":
+		e.opts.posterize, err = strconv.Atoi(value)
+		if err == nil && e.opts.posterize != 0 && (e.opts.posterize < 2 || e.opts.posterize > 256) {
+			return fmt.Errorf("expected 2-256, or 0 to disable")
+		}
+	case "gain-r":
+		e.opts.gainR, err = parseNonNegativeFloat(value)
+	case "gain-g":
+		e.opts.gainG, err = parseNonNegativeFloat(value)
+	case "gain-b":
+		e.opts.gainB, err = parseNonNegativeFloat(value)
+	case "equalize":
+		e.opts.equalize, err = strconv.ParseBool(value)
+	case "auto-contrast":
+		e.opts.autoContrast, err = strconv.ParseBool(value)
+	case "ramp-gamma":
+		e.opts.rampGamma, err = parsePositiveFloat(value)
+	case "adaptive-detail":
+		e.opts.adaptiveDetail, err = strconv.ParseBool(value)
+	case "detail-threshold":
+		e.opts.detailThreshold, err = parseNonNegativeFloat(value)
+	case "cell-budget":
+		e.opts.cellBudget, err = parsePositiveInt(value)
+	case "downscale-only":
+		e.opts.downscaleOnly, err = strconv.ParseBool(value)
+	case "char-detail":
+		e.opts.charDetail, err = strconv.Atoi(value)
+		if err == nil && e.opts.charDetail < 0 {
+			return fmt.Errorf("expected 0 or a positive integer")
+		}
+	case "transparent-as":
+		if utf8.RuneCountInString(value) != 1 {
+			return fmt.Errorf("expected a single character")
+		}
+		e.opts.transparentChar = value
+	case "alpha-weighted":
+		e.opts.alphaWeighted, err = strconv.ParseBool(value)
+	case "save":
+		e.out.save = value
+	case "format":
+		switch value {
+		case formatText, formatPNG, formatSVG, formatGo, formatANS:
+			e.out.format = value
+		default:
+			return fmt.Errorf("expected %s, %s, %s, %s or %s", formatText, formatPNG, formatSVG, formatGo, formatANS)
+		}
+	case "compact-color":
+		e.out.compactColor, err = strconv.ParseBool(value)
+	case "trim-trailing":
+		e.out.trimTrailing, err = strconv.ParseBool(value)
+	case "title":
+		e.out.title = value
+	case "author":
+		e.out.author = value
+	case "var":
+		if !token.IsIdentifier(value) {
+			return fmt.Errorf("'%s' is not a valid Go identifier", value)
+		}
+		e.out.varName = value
+	default:
+		return fmt.Errorf("unknown option")
+	}
+	return err
+}
+
+// parsePositiveInt parses an integer that must be at least 1.
+func parsePositiveInt(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err == nil && n < 1 {
+		err = fmt.Errorf("expected a positive integer")
+	}
+	return n, err
+}
+
+// parsePositiveFloat parses a number that must be greater than zero.
+func parsePositiveFloat(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err == nil && f <= 0 {
+		err = fmt.Errorf("expected a positive number")
+	}
+	return f, err
+}
+
+// parseNonNegativeFloat parses a number that must not be negative.
+func parseNonNegativeFloat(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err == nil && f < 0 {
+		err = fmt.Errorf("expected a non-negative number")
+	}
+	return f, err
+}
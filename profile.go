@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiling begins CPU profiling when cpuPath is set and returns a stop
+// function to call once conversion is done. Stopping finishes the CPU profile
+// and, when memPath is set, writes a heap profile of the live allocations.
+func startProfiling(cpuPath, memPath string) (stop func(), err error) {
+	var cpuFile *os.File
+	if cpuPath != "" {
+		cpuFile, err = os.Create(cpuPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, err
+		}
+	}
+
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+
+		if memPath != "" {
+			memFile, err := os.Create(memPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to create memory profile '%s': %v\n", memPath, err)
+				return
+			}
+			defer memFile.Close()
+
+			// Collect garbage first so the profile reflects live memory
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(memFile); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to write memory profile '%s': %v\n", memPath, err)
+			}
+		}
+	}, nil
+}
+
+// mustStartProfiling starts the requested profiles, exiting on failure.
+func mustStartProfiling(cpuPath, memPath string) func() {
+	stop, err := startProfiling(cpuPath, memPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to start CPU profile '%s': %v\n", cpuPath, err)
+		os.Exit(1)
+	}
+	return stop
+}
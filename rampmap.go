@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rampMap assigns a character to every 8-bit luminance value, replacing the
+// evenly spaced palette lookup with hand-tuned ranges (-ramp-map).
+type rampMap [256]byte
+
+// rampRange is one entry of a ramp map file: the character drawn for
+// luminance values lo through hi.
+type rampRange struct {
+	lo, hi int
+	char   byte
+	line   int
+}
+
+// loadRampMap reads a ramp map file. Each non-empty line that does not start
+// with '#' holds an inclusive luminance range and the character drawn for it,
+// from dark to light, e.g.
+//
+//	0-30 @
+//	31-80 #
+//	81-255 ' '
+//
+// The character may be quoted Go-style, which is needed for a space. The
+// ranges must not overlap and must cover 0-255 without gaps, and characters
+// are limited to printable ASCII so -safe-ascii output stays ASCII.
+func loadRampMap(path string) (*rampMap, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var ranges []rampRange
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		r, err := parseRampRange(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+		r.line = lineNum
+		ranges = append(ranges, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Every luminance value must be covered exactly once
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].lo < ranges[j].lo })
+	var m rampMap
+	next := 0
+	for _, r := range ranges {
+		if r.lo < next {
+			return nil, fmt.Errorf("%s:%d: range %d-%d overlaps luminance %d", path, r.line, r.lo, r.hi, r.lo)
+		}
+		if r.lo > next {
+			return nil, fmt.Errorf("%s: luminance %d-%d is not covered", path, next, r.lo-1)
+		}
+		for v := r.lo; v <= r.hi; v++ {
+			m[v] = r.char
+		}
+		next = r.hi + 1
+	}
+	if next <= 255 {
+		return nil, fmt.Errorf("%s: luminance %d-255 is not covered", path, next)
+	}
+	return &m, nil
+}
+
+// parseRampRange parses one "lo-hi char" line of a ramp map file.
+func parseRampRange(text string) (rampRange, error) {
+	bounds, char, ok := strings.Cut(text, " ")
+	if !ok {
+		return rampRange{}, fmt.Errorf("expected a range and a character, e.g. 0-30 @")
+	}
+	char = strings.TrimSpace(char)
+	if strings.HasPrefix(char, "'") || strings.HasPrefix(char, "\"") {
+		unquoted, err := strconv.Unquote(char)
+		if err != nil {
+			return rampRange{}, fmt.Errorf("invalid quoted character %s", char)
+		}
+		char = unquoted
+	}
+	if len(char) != 1 || char[0] < ' ' || char[0] > '~' {
+		return rampRange{}, fmt.Errorf("'%s' is not a single printable ASCII character", char)
+	}
+
+	loText, hiText, ok := strings.Cut(bounds, "-")
+	if !ok {
+		return rampRange{}, fmt.Errorf("range '%s' is not in lo-hi form", bounds)
+	}
+	lo, errLo := strconv.Atoi(loText)
+	hi, errHi := strconv.Atoi(hiText)
+	if errLo != nil || errHi != nil || lo < 0 || hi > 255 || lo > hi {
+		return rampRange{}, fmt.Errorf("range '%s' must be lo-hi with 0 <= lo <= hi <= 255", bounds)
+	}
+	return rampRange{lo: lo, hi: hi, char: char[0]}, nil
+}
+
+// chars lists the map's characters from dark to light, once per range.
+func (m *rampMap) chars() string {
+	var sb strings.Builder
+	for v, c := range m {
+		if v == 0 || c != m[v-1] {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
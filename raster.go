@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Supported values for the -format flag.
+const (
+	formatText = "text"
+	formatPNG  = "png"
+	formatSVG  = "svg"
+)
+
+// rasterFontSize is the font size, in pixels, used for PNG and SVG output.
+const rasterFontSize = 14
+
+// Colors used for cells that carry no color escapes. Monochrome art is drawn
+// dark-on-light so the palette reads the same way it does in a text editor.
+var (
+	monoBackground  = color.RGBA{255, 255, 255, 255}
+	monoForeground  = color.RGBA{0, 0, 0, 255}
+	colorBackground = color.RGBA{0, 0, 0, 255}
+	colorForeground = color.RGBA{229, 229, 229, 255}
+)
+
+// rasterFont is the typeface used to draw characters in PNG and SVG output.
+type rasterFont struct {
+	face   font.Face
+	family string // CSS font-family used in SVG output
+
+	// Cell metrics in pixels
+	cellWidth, cellHeight, ascent int
+	letterSpacing                 int // extra horizontal space per cell
+}
+
+// loadFont loads a TrueType or OpenType font file for raster output. An empty
+// path selects the bundled Go Mono typeface.
+func loadFont(path string) (rasterFont, error) {
+	data := gomono.TTF
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return rasterFont{}, err
+		}
+	}
+
+	parsed, err := opentype.Parse(data)
+	if err != nil {
+		return rasterFont{}, err
+	}
+
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{
+		Size:    rasterFontSize,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return rasterFont{}, err
+	}
+
+	// Use the font's own family name in SVG, falling back to any monospace font
+	family := "monospace"
+	if name, err := parsed.Name(nil, sfnt.NameIDFamily); err == nil && name != "" {
+		family = fmt.Sprintf("'%s', monospace", name)
+	}
+
+	// Cells are sized from the advance of a wide glyph and the line height
+	advance, ok := face.GlyphAdvance('M')
+	if !ok {
+		return rasterFont{}, fmt.Errorf("font has no glyph for 'M'")
+	}
+	metrics := face.Metrics()
+
+	return rasterFont{
+		face:       face,
+		family:     family,
+		cellWidth:  advance.Ceil(),
+		cellHeight: metrics.Height.Ceil(),
+		ascent:     metrics.Ascent.Ceil(),
+	}, nil
+}
+
+// outputFont loads the font selected for raster output, falling back to the
+// bundled default with a warning, and applies the requested cell spacing.
+func outputFont(out outputOptions) rasterFont {
+	rf, err := loadFont(out.fontPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to load font '%s': %v (using the bundled default)\n", out.fontPath, err)
+		rf, _ = loadFont("")
+	}
+	return rf.spaced(out.letterSpacing, out.lineSpacing)
+}
+
+// spaced widens or narrows the cells by the given number of pixels. Extra line
+// spacing is split above and below the glyphs so they stay vertically
+// centered, and cells never shrink below a single pixel.
+func (rf rasterFont) spaced(letter, line int) rasterFont {
+	rf.cellWidth = max(1, rf.cellWidth+letter)
+	rf.cellHeight = max(1, rf.cellHeight+line)
+	rf.ascent += line / 2
+	rf.letterSpacing = letter
+	return rf
+}
+
+// parseSpacing parses a -letter-spacing or -line-spacing value, either a whole
+// number of pixels such as "2" or a fraction of the font size such as
+// "0.25em". Negative values tighten the spacing.
+func parseSpacing(value string) (int, error) {
+	if ems, ok := strings.CutSuffix(value, "em"); ok {
+		f, err := strconv.ParseFloat(ems, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected pixels or em, e.g. 2 or 0.25em")
+		}
+		return int(math.Round(f * rasterFontSize)), nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(value, "px"))
+	if err != nil {
+		return 0, fmt.Errorf("expected pixels or em, e.g. 2 or 0.25em")
+	}
+	return n, nil
+}
+
+// rasterGrid parses rendered lines into cells and picks the default colors
+// for the canvas. Plain monochrome art uses a light background, while art
+// containing color escapes uses a dark one like a terminal would.
+func rasterGrid(art []string) (cells [][]styledCell, cols int, fg, bg color.RGBA) {
+	colored := false
+	for _, line := range art {
+		row := parseCells(line)
+		for _, c := range row {
+			if c.hasFg || c.hasBg {
+				colored = true
+			}
+		}
+		if w := visibleWidth(line); w > cols {
+			cols = w
+		}
+		cells = append(cells, row)
+	}
+
+	if colored {
+		return cells, cols, colorForeground, colorBackground
+	}
+	return cells, cols, monoForeground, monoBackground
+}
+
+// writePNG rasterizes the rendered lines into a PNG image, drawing each
+// character in its cell with the foreground and background colors from the
+// line's escape sequences. Half blocks are drawn as two filled rectangles so
+// they stay pixel-exact regardless of the font's glyph coverage.
+func writePNG(w io.Writer, art []string, rf rasterFont) error {
+	cells, cols, defaultFg, defaultBg := rasterGrid(art)
+
+	canvas := image.NewRGBA(image.Rect(0, 0, cols*rf.cellWidth, len(cells)*rf.cellHeight))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(defaultBg), image.Point{}, draw.Src)
+
+	drawer := &font.Drawer{Dst: canvas, Face: rf.face}
+	for y, row := range cells {
+		for x, c := range row {
+			fg, bg := defaultFg, defaultBg
+			if c.hasFg {
+				fg = c.fg
+			}
+			if c.hasBg {
+				bg = c.bg
+			}
+
+			cell := image.Rect(x*rf.cellWidth, y*rf.cellHeight, (x+1)*rf.cellWidth, (y+1)*rf.cellHeight)
+			if c.hasBg {
+				draw.Draw(canvas, cell, image.NewUniform(bg), image.Point{}, draw.Src)
+			}
+
+			switch c.char {
+			case ' ':
+				// Nothing to draw beyond the background
+			case []rune(upperHalfBlock)[0]:
+				top := cell
+				top.Max.Y = cell.Min.Y + rf.cellHeight/2
+				draw.Draw(canvas, top, image.NewUniform(fg), image.Point{}, draw.Src)
+			default:
+				drawer.Src = image.NewUniform(fg)
+				drawer.Dot = fixed.P(cell.Min.X, cell.Min.Y+rf.ascent)
+				drawer.DrawString(string(c.char))
+			}
+		}
+	}
+
+	return png.Encode(w, canvas)
+}
+
+// writeSVG renders the lines as an SVG document sized to the same cell grid
+// as the PNG output. Background colors become rectangles and each run of
+// equally colored characters becomes a positioned tspan.
+func writeSVG(w io.Writer, art []string, rf rasterFont) error {
+	cells, cols, defaultFg, defaultBg := rasterGrid(art)
+	width := cols * rf.cellWidth
+	height := len(cells) * rf.cellHeight
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+	fmt.Fprintf(&sb, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", hexColor(defaultBg))
+	spacing := ""
+	if rf.letterSpacing != 0 {
+		spacing = fmt.Sprintf(" letter-spacing=\"%d\"", rf.letterSpacing)
+	}
+	fmt.Fprintf(&sb, "<g font-family=\"%s\" font-size=\"%d\"%s xml:space=\"preserve\">\n", html.EscapeString(rf.family), rasterFontSize, spacing)
+
+	for y, row := range cells {
+		top := y * rf.cellHeight
+
+		// Background colors and half blocks are drawn as cell rectangles
+		for x, c := range row {
+			if c.hasBg {
+				fmt.Fprintf(&sb, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n",
+					x*rf.cellWidth, top, rf.cellWidth, rf.cellHeight, hexColor(c.bg))
+			}
+			if string(c.char) == upperHalfBlock {
+				fg := defaultFg
+				if c.hasFg {
+					fg = c.fg
+				}
+				fmt.Fprintf(&sb, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n",
+					x*rf.cellWidth, top, rf.cellWidth, rf.cellHeight/2, hexColor(fg))
+			}
+		}
+
+		// Group the remaining characters into runs sharing a foreground color
+		fmt.Fprintf(&sb, "<text y=\"%d\">", top+rf.ascent)
+		for x := 0; x < len(row); {
+			fg := defaultFg
+			if row[x].hasFg {
+				fg = row[x].fg
+			}
+
+			var run strings.Builder
+			end := x
+			for ; end < len(row); end++ {
+				c := row[end]
+				cfg := defaultFg
+				if c.hasFg {
+					cfg = c.fg
+				}
+				if cfg != fg {
+					break
+				}
+				if string(c.char) == upperHalfBlock {
+					run.WriteRune(' ')
+				} else {
+					run.WriteRune(c.char)
+				}
+			}
+
+			if strings.TrimSpace(run.String()) != "" {
+				fmt.Fprintf(&sb, "<tspan x=\"%d\" fill=\"%s\">%s</tspan>", x*rf.cellWidth, hexColor(fg), html.EscapeString(run.String()))
+			}
+			x = end
+		}
+		sb.WriteString("</text>\n")
+	}
+
+	sb.WriteString("</g>\n</svg>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// hexColor formats a color as a CSS hex triplet.
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,53 @@
+package main
+
+import "image"
+
+// Supported values for the -sampling flag.
+const (
+	samplingBlock = "block" // average a grid of samples from the truncated pixel block
+	samplingArea  = "area"  // average every pixel the cell covers, weighted by coverage
+)
+
+// sampleArea returns the average color of the exact source region of a cell,
+// weighting each pixel by how much of it the region covers, like a box filter
+// with fractional edges. Unlike the block sampler, whose edges are truncated
+// to whole pixels, neighboring cells share their edge pixels in proportion, so
+// downscales to sizes that do not divide the image evenly stay smooth. The
+// cell's rows are given in half rows (halfRow and halfRows) so the half-block
+// renderer can sample either half of a cell. Alpha weighting and the
+// checkerboard apply as for the block sampler.
+func (o options) sampleArea(img image.Image, gr grid, col, halfRow, halfRows int) (r, g, b uint64) {
+	// Edges are scaled by the cell counts so every weight is an exact integer
+	xd, yd := gr.tileCols, 2*gr.tileRows
+	x0, x1 := col*gr.imgWidth, (col+1)*gr.imgWidth
+	y0, y1 := halfRow*gr.imgHeight, (halfRow+halfRows)*gr.imgHeight
+
+	bounds := img.Bounds()
+	var rSum, gSum, bSum, aSum, n uint64
+	for py := y0 / yd; py*yd < y1; py++ {
+		wy := min(py*yd+yd, y1) - max(py*yd, y0)
+		for px := x0 / xd; px*xd < x1; px++ {
+			wx := min(px*xd+xd, x1) - max(px*xd, x0)
+			w := uint64(wx * wy)
+
+			pr, pg, pb, pa := img.At(bounds.Min.X+px%bounds.Dx(), bounds.Min.Y+py%bounds.Dy()).RGBA()
+			rSum += uint64(pr) * w
+			gSum += uint64(pg) * w
+			bSum += uint64(pb) * w
+			aSum += uint64(pa) * w
+			n += w
+		}
+	}
+
+	switch {
+	case o.checkerSize > 0:
+		behind := o.checkerShade(col, halfRow) * (0xffff - aSum/n) / 0xffff
+		return rSum/n + behind, gSum/n + behind, bSum/n + behind
+	case o.alphaWeighted:
+		if aSum == 0 {
+			return 0, 0, 0
+		}
+		return rSum * 0xffff / aSum, gSum * 0xffff / aSum, bSum * 0xffff / aSum
+	}
+	return rSum / n, gSum / n, bSum / n
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// formatANS emits the art as an ANSI art file with a SAUCE record.
+const formatANS = "ans"
+
+// Field widths of the SAUCE record that are filled from flags.
+const (
+	sauceTitleLen  = 35
+	sauceAuthorLen = 20
+)
+
+// cp437 maps the non-ASCII glyphs pixelterm renders with to code page 437,
+// the character set ANSI art viewers read .ans files in.
+var cp437 = map[rune]byte{
+	'░': 0xb0,
+	'▒': 0xb1,
+	'▓': 0xb2,
+	'█': 0xdb,
+	'▄': 0xdc,
+	'▌': 0xdd,
+	'▐': 0xde,
+	'▀': 0xdf,
+	'·': 0xfa,
+	'■': 0xfe,
+}
+
+// ansFile encodes the art as an ANSI art file: the lines in code page 437 with
+// CRLF line endings, followed by the end-of-file marker and a SAUCE record
+// describing it as ANSi character art of the art's width and height. Glyphs
+// without a code page 437 equivalent become asciiReplacement. The title and
+// author are cut to fit their fields.
+func ansFile(art []string, title, author string) []byte {
+	var buf bytes.Buffer
+	width := 0
+	for _, line := range art {
+		for _, r := range line {
+			switch b, ok := cp437[r]; {
+			case r < 0x80:
+				buf.WriteByte(byte(r))
+			case ok:
+				buf.WriteByte(b)
+			default:
+				buf.WriteByte(asciiReplacement)
+			}
+		}
+		buf.WriteString("\r\n")
+		width = max(width, visibleWidth(line))
+	}
+	size := buf.Len()
+
+	// SAUCE 00.00: https://www.acid.org/info/sauce/sauce.htm
+	buf.WriteByte(0x1a)
+	buf.WriteString("SAUCE00")
+	buf.WriteString(sauceField(title, sauceTitleLen))
+	buf.WriteString(sauceField(author, sauceAuthorLen))
+	buf.WriteString(sauceField("", 20)) // group
+	buf.WriteString(time.Now().Format("20060102"))
+	binary.Write(&buf, binary.LittleEndian, uint32(size))
+	buf.WriteByte(1) // data type: character
+	buf.WriteByte(1) // file type: ANSi
+	binary.Write(&buf, binary.LittleEndian, [4]uint16{uint16(width), uint16(len(art)), 0, 0})
+	buf.WriteByte(0) // no comment block
+	buf.WriteByte(0) // flags: blinking, default letter spacing and aspect ratio
+	buf.Write(make([]byte, 22))
+	return buf.Bytes()
+}
+
+// sauceField pads or cuts a string to a space-padded SAUCE field of n bytes,
+// replacing anything outside printable ASCII.
+func sauceField(s string, n int) string {
+	field := []byte(toSafeASCII(s))
+	if len(field) > n {
+		field = field[:n]
+	}
+	return string(field) + strings.Repeat(" ", n-len(field))
+}
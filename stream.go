@@ -0,0 +1,17 @@
+package main
+
+import "io"
+
+// lineWriter returns an emit function that writes each line and a newline to
+// w, along with a function reporting the first write error. Once a write has
+// failed the remaining lines are discarded.
+func lineWriter(w io.Writer) (emit func(line string), writeErr func() error) {
+	var err error
+	emit = func(line string) {
+		if err != nil {
+			return
+		}
+		_, err = io.WriteString(w, line+"\n")
+	}
+	return emit, func() error { return err }
+}
@@ -0,0 +1,28 @@
+package main
+
+import "os"
+
+// Escape sequences used for full-screen output.
+const (
+	altScreenOn  = "\x1b[?1049h\x1b[?25l" // switch to the alternate screen, hide the cursor
+	altScreenOff = "\x1b[?25h\x1b[?1049l" // show the cursor, restore the main screen
+	clearScreen  = "\x1b[H\x1b[2J"
+)
+
+// winsize mirrors struct winsize from <sys/ioctl.h>.
+type winsize struct {
+	rows, cols, xPixels, yPixels uint16
+}
+
+// openTerminal opens the controlling terminal for interactive input and
+// output, independent of where stdin and stdout are redirected.
+func openTerminal() (*os.File, error) {
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
+}
+
+// isTerminal reports whether f is connected to a terminal rather than a file
+// or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
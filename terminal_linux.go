@@ -0,0 +1,55 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// terminalSize returns the size of the terminal in cells and in pixels, as
+// reported by TIOCGWINSZ. Terminals that don't know their pixel size leave it
+// zero.
+func terminalSize(tty *os.File) (winsize, error) {
+	var ws winsize
+	err := ioctl(tty.Fd(), syscall.TIOCGWINSZ, unsafe.Pointer(&ws))
+	return ws, err
+}
+
+// rawMode switches the terminal to non-canonical mode without echo or signal
+// keys, so input arrives byte by byte and Ctrl-C can be handled as a key. With
+// a zero timeout reads block until a byte arrives; otherwise they return empty
+// after the timeout (rounded to tenths of a second). The returned function
+// restores the previous settings.
+func rawMode(tty *os.File, timeout time.Duration) (restore func(), err error) {
+	fd := tty.Fd()
+
+	var saved syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&saved)); err != nil {
+		return nil, err
+	}
+
+	raw := saved
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if timeout > 0 {
+		raw.Cc[syscall.VMIN] = 0
+		raw.Cc[syscall.VTIME] = uint8(max(1, timeout/(100*time.Millisecond)))
+	}
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+
+	return func() { ioctl(fd, syscall.TCSETS, unsafe.Pointer(&saved)) }, nil
+}
+
+// ioctl performs an ioctl system call with a pointer argument.
+func ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// errNoTerminal is returned by the terminal helpers on platforms where they
+// are not implemented.
+var errNoTerminal = fmt.Errorf("terminal control is not supported on this platform")
+
+// terminalSize is only implemented for Linux terminals.
+func terminalSize(tty *os.File) (winsize, error) {
+	return winsize{}, errNoTerminal
+}
+
+// rawMode is only implemented for Linux terminals.
+func rawMode(tty *os.File, timeout time.Duration) (restore func(), err error) {
+	return nil, errNoTerminal
+}
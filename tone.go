@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sync"
+)
+
+// toneCurve remaps 8-bit luminance values before palette lookup. It is built
+// from a first pass over the whole image, so global adjustments such as
+// histogram equalization can be applied while rows still render in parallel.
+type toneCurve [256]uint8
+
+// cellGray returns the luminance that the active renderer would use for
+// character selection of a cell with the given averaged 16-bit color, before
+// any tone curve is applied.
+func (o options) cellGray(r, g, b uint64) uint64 {
+	if o.color || o.halfBlock {
+		return luminance(o.adjustColor(r, g, b))
+	}
+	return o.adjustGray(o.monoLuma(o.applyGains(r, g, b)))
+}
+
+// charGray returns the luminance used to pick a cell's character, given the
+// value derived from its averaged color. With -char-detail the block is
+// resampled on a finer N×N grid and the per-sample luminance is averaged
+// instead, so thin features reach the character choice while the color stays
+// averaged over the full cell.
+func (o options) charGray(img image.Image, x0, y0, x1, y1 int, gray uint64) uint64 {
+	if o.charDetail < 1 {
+		return gray
+	}
+
+	var sum uint64
+	n := 0
+	forEachSample(img, x0, y0, x1, y1, o.charDetail, func(r, g, b, _ uint32) {
+		sum += o.cellGray(uint64(r), uint64(g), uint64(b))
+		n++
+	})
+	return sum / uint64(n)
+}
+
+// charFor maps a cell's luminance to its character in the ramp. The value
+// passes through the tone curve first, when one has been computed, and then
+// through the ramp gamma, which only changes how luminance maps to ramp
+// positions and never the colors themselves. A -ramp-map replaces the ramp
+// lookup at the end.
+func (o options) charFor(ramp string, gray uint64) byte {
+	if o.curve != nil {
+		gray = uint64(o.curve[gray])
+	}
+	if o.rampGamma != 1 && o.rampGamma > 0 {
+		gray = uint64(math.Round(255 * math.Pow(float64(gray)/255, o.rampGamma)))
+	}
+	if o.rampMap != nil {
+		return o.rampMap[gray]
+	}
+	return paletteChar(ramp, gray)
+}
+
+// rampFor picks the character ramp for the cell covering the given source
+// block. In -adaptive-detail mode busy cells get the finer detail ramp so
+// texture survives, while flat cells keep the simpler palette.
+func (o options) rampFor(img image.Image, x0, y0, x1, y1 int) string {
+	if o.adaptiveDetail && blockStdDev(img, x0, y0, x1, y1) > o.detailThreshold {
+		return detailPalette
+	}
+	return palette
+}
+
+// checkToneCurves rejects requests for more than one global tone curve, since
+// prepareCurve can only apply one of them.
+func checkToneCurves(equalize, autoContrast bool, blackPoint, whitePoint float64) error {
+	curves := 0
+	for _, set := range []bool{equalize, autoContrast, blackPoint != 0 || whitePoint != 100} {
+		if set {
+			curves++
+		}
+	}
+	if curves > 1 {
+		return fmt.Errorf("-equalize, -auto-contrast and -black-point/-white-point cannot be combined")
+	}
+	return nil
+}
+
+// prepareCurve runs the global first pass when an option needs one and returns
+// options carrying the resulting tone curve.
+func (o options) prepareCurve(img image.Image) options {
+	switch {
+	case o.equalize:
+		curve := equalizeCurve(o.lumaHistogram(img))
+		o.curve = &curve
+	case o.autoContrast:
+		// Auto-contrast is levels with the same clip at both ends
+		curve := levelsCurve(o.lumaHistogram(img), o.contrastClip, 100-o.contrastClip)
+		o.curve = &curve
+	case o.blackPoint > 0 || o.whitePoint < 100:
+		curve := levelsCurve(o.lumaHistogram(img), o.blackPoint, o.whitePoint)
+		o.curve = &curve
+	}
+	return o
+}
+
+// lumaHistogram counts the cells of the output grid at each luminance level.
+// Rows are sampled in parallel, each accumulating into its own histogram.
+func (o options) lumaHistogram(img image.Image) [256]int {
+	gr := o.layout(img.Bounds())
+
+	var mu sync.Mutex
+	var hist [256]int
+
+	forEachRow(gr.rows, func(rowIndex int) {
+		var rowHist [256]int
+		imgY, imgYEnd := span(rowIndex, gr.tileRows, gr.imgHeight)
+		for x := 0; x < gr.cols; x++ {
+			imgX, imgXEnd := span(x, gr.tileCols, gr.imgWidth)
+			gray := o.cellGray(o.sampleColor(img, gr, x, 2*rowIndex, 2))
+			rowHist[o.charGray(img, imgX, imgY, imgXEnd, imgYEnd, gray)]++
+		}
+
+		mu.Lock()
+		for i, n := range rowHist {
+			hist[i] += n
+		}
+		mu.Unlock()
+	})
+
+	return hist
+}
+
+// equalizeCurve builds the histogram equalization curve, mapping each level to
+// its position in the cumulative distribution so tones spread evenly over the
+// full 0-255 range.
+func equalizeCurve(hist [256]int) toneCurve {
+	var curve toneCurve
+
+	total := 0
+	for _, n := range hist {
+		total += n
+	}
+
+	// The darkest occupied level maps to 0
+	cdfMin := 0
+	for _, n := range hist {
+		if n > 0 {
+			cdfMin = n
+			break
+		}
+	}
+
+	// A flat image has nothing to spread, leave it unchanged
+	if total == cdfMin {
+		for i := range curve {
+			curve[i] = uint8(i)
+		}
+		return curve
+	}
+
+	cdf := 0
+	for i, n := range hist {
+		cdf += n
+		v := (cdf - cdfMin) * 255 / (total - cdfMin)
+		if v < 0 {
+			v = 0
+		}
+		curve[i] = uint8(v)
+	}
+	return curve
+}
+
+// levelsCurve builds a linear tone curve that maps the black point to 0 and
+// the white point to 255. Both points are given as percentiles of the cells in
+// the histogram, so a few outliers cannot pin the range in place. Levels
+// outside the range saturate at the ends of the palette.
+func levelsCurve(hist [256]int, blackPct, whitePct float64) toneCurve {
+	var curve toneCurve
+
+	total := 0
+	for _, n := range hist {
+		total += n
+	}
+	skipLow := int(float64(total) * blackPct / 100)
+	skipHigh := int(float64(total) * (100 - whitePct) / 100)
+
+	// Find the darkest and brightest levels left after clipping
+	low, seen := 0, 0
+	for ; low < 255; low++ {
+		seen += hist[low]
+		if seen > skipLow {
+			break
+		}
+	}
+	high, seen := 255, 0
+	for ; high > 0; high-- {
+		seen += hist[high]
+		if seen > skipHigh {
+			break
+		}
+	}
+
+	// A flat image has nothing to stretch, leave it unchanged
+	if high <= low {
+		for i := range curve {
+			curve[i] = uint8(i)
+		}
+		return curve
+	}
+
+	for i := range curve {
+		v := (i - low) * 255 / (high - low)
+		curve[i] = uint8(min(max(v, 0), 255))
+	}
+	return curve
+}
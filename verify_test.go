@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// TestRowOrder renders a vertical gradient, dark at the top and light at the
+// bottom, through each renderer and checks that brightness never decreases
+// down the rows. Rows are rendered concurrently and reassembled by index, so a
+// mistake in that bookkeeping shows up as rows out of order. The gradient is
+// tall enough to keep more rows in flight than the row window.
+func TestRowOrder(t *testing.T) {
+	defer func(jobs, window int) { renderJobs, rowWindow = jobs, window }(renderJobs, rowWindow)
+
+	for _, jobs := range []int{1, 8} {
+		renderJobs, rowWindow = jobs, 4*jobs
+		img := gradient(2*rowWindow + 3)
+
+		// Neutral settings so each row's brightness follows the gradient directly
+		base := options{width: 16, scale: 0.5, colorMode: colorModeTrue, gainR: 1, gainG: 1, gainB: 1, rampGamma: 1}
+		colored := base
+		colored.color = true
+		halfBlock := colored
+		halfBlock.halfBlock = true
+
+		for _, c := range []struct {
+			name string
+			opts options
+		}{
+			{"mono", base},
+			{"color", colored},
+			{"halfblock", halfBlock},
+		} {
+			t.Run(fmt.Sprintf("%s/jobs=%d", c.name, jobs), func(t *testing.T) {
+				var levels []int
+				c.opts.render(img, func(line string) {
+					levels = append(levels, rowLevels(line, c.opts)...)
+				})
+				if err := checkOrder(levels); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+
+// gradient returns a 64 pixel wide image of rows*8 pixels whose brightness
+// increases from black at the top to white at the bottom.
+func gradient(rows int) image.Image {
+	img := image.NewGray16(image.Rect(0, 0, 64, rows*8))
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		v := color.Gray16{Y: uint16(y * 0xffff / (img.Bounds().Dy() - 1))}
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			img.SetGray16(x, y, v)
+		}
+	}
+	return img
+}
+
+// rowLevels returns the brightness of a rendered row as it appears from top to
+// bottom: the palette position of its first character in mono mode, the
+// foreground red channel in color mode, and the foreground then background red
+// channel for the two pixel rows of a half block.
+func rowLevels(line string, opts options) []int {
+	cells := parseCells(line)
+	if len(cells) == 0 {
+		return []int{-1}
+	}
+
+	first := cells[0]
+	switch {
+	case opts.halfBlock:
+		return []int{int(first.fg.R), int(first.bg.R)}
+	case opts.color:
+		return []int{int(first.fg.R)}
+	default:
+		return []int{strings.IndexRune(palette, first.char)}
+	}
+}
+
+// checkOrder reports the first position where levels decreases.
+func checkOrder(levels []int) error {
+	for i := 1; i < len(levels); i++ {
+		if levels[i] < levels[i-1] {
+			return fmt.Errorf("brightness drops from %d to %d at row %d of %d", levels[i-1], levels[i], i, len(levels))
+		}
+	}
+	return nil
+}